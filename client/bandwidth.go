@@ -0,0 +1,258 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// bandwidthChunk is the most a limited F connection reads or writes at once, so that
+// the rate stays even and a lowered limit takes effect quickly.
+const bandwidthChunk = 16 * 1024
+
+// Limits are transfer rates in bytes per second. Zero does not limit.
+type Limits struct {
+	// Upload and Download limit all transfers together.
+	Upload   int64
+	Download int64
+	// PeerUpload and PeerDownload limit the transfers to and from a single user.
+	PeerUpload   int64
+	PeerDownload int64
+}
+
+// BandwidthPeriod is a time of day with its own Limits, ie. full speed at night:
+// BandwidthPeriod{From: 23 * time.Hour, To: 7 * time.Hour}. From and To are local
+// time since midnight, a period with From after To goes past midnight.
+type BandwidthPeriod struct {
+	From time.Duration
+	To   time.Duration
+	Limits
+}
+
+// Bandwidth are the rate limits of F connections. The first of Schedule that is on
+// applies instead of Limits.
+type Bandwidth struct {
+	Limits
+	Schedule []BandwidthPeriod
+}
+
+// limits returns the limits that apply at now.
+func (b Bandwidth) limits(now time.Time) Limits {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := now.Sub(midnight)
+
+	for _, p := range b.Schedule {
+		if p.From <= p.To && day >= p.From && day < p.To {
+			return p.Limits
+		}
+
+		if p.From > p.To && (day >= p.From || day < p.To) {
+			return p.Limits
+		}
+	}
+
+	return b.Limits
+}
+
+// SetBandwidth replaces Config.Bandwidth, for transfers that are running too.
+func (s *State) SetBandwidth(b Bandwidth) {
+	s.bandwidth.set(b)
+}
+
+// bandwidth enforces Bandwidth with a token bucket per direction, in total and per user.
+type bandwidth struct {
+	config   Bandwidth
+	upload   *bucket
+	download *bucket
+	peers    map[string]*peerBuckets
+	mu       sync.RWMutex
+}
+
+// peerBuckets are the buckets of a user, shared by its connections.
+type peerBuckets struct {
+	upload   *bucket
+	download *bucket
+	conns    int
+}
+
+// newBandwidth returns a bandwidth enforcing b.
+func newBandwidth(b Bandwidth) *bandwidth {
+	return &bandwidth{
+		config:   b,
+		upload:   new(bucket),
+		download: new(bucket),
+		peers:    make(map[string]*peerBuckets),
+	}
+}
+
+// set replaces the limits enforced.
+func (b *bandwidth) set(config Bandwidth) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.config = config
+}
+
+// limits returns the limits that apply at now.
+func (b *bandwidth) limits(now time.Time) Limits {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.config.limits(now)
+}
+
+// conn returns conn, an F connection with username, limited to the bandwidth.
+func (b *bandwidth) conn(conn net.Conn, username string) net.Conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, found := b.peers[username]
+	if !found {
+		p = &peerBuckets{upload: new(bucket), download: new(bucket)}
+		b.peers[username] = p
+	}
+
+	p.conns++
+
+	return &limitedConn{
+		Conn:      conn,
+		bandwidth: b,
+		username:  username,
+		peer:      p,
+		closed:    make(chan struct{}),
+	}
+}
+
+// release drops username's buckets once none of its connections is left.
+func (b *bandwidth) release(username string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, found := b.peers[username]
+	if !found {
+		return
+	}
+
+	p.conns--
+	if p.conns <= 0 {
+		delete(b.peers, username)
+	}
+}
+
+// limitedConn is an F connection whose reads and writes wait for the bandwidth.
+type limitedConn struct {
+	net.Conn
+	bandwidth *bandwidth
+	username  string
+	peer      *peerBuckets
+	closed    chan struct{}
+	close     sync.Once
+}
+
+// Read reads at most bandwidthChunk bytes, then waits until the download limits allow
+// for them.
+func (c *limitedConn) Read(p []byte) (int, error) {
+	if len(p) > bandwidthChunk {
+		p = p[:bandwidthChunk]
+	}
+
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		now := time.Now()
+		l := c.bandwidth.limits(now)
+
+		c.wait(max(
+			c.bandwidth.download.take(l.Download, n, now),
+			c.peer.download.take(l.PeerDownload, n, now),
+		))
+	}
+
+	return n, err
+}
+
+// Write writes p in chunks of bandwidthChunk bytes, each once the upload limits allow.
+func (c *limitedConn) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), bandwidthChunk)]
+
+		now := time.Now()
+		l := c.bandwidth.limits(now)
+
+		c.wait(max(
+			c.bandwidth.upload.take(l.Upload, len(chunk), now),
+			c.peer.upload.take(l.PeerUpload, len(chunk), now),
+		))
+
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// Close closes the connection, cutting a wait for the bandwidth short.
+func (c *limitedConn) Close() error {
+	c.close.Do(func() {
+		close(c.closed)
+		c.bandwidth.release(c.username)
+	})
+
+	return c.Conn.Close()
+}
+
+// wait waits for d, or until the connection is closed.
+func (c *limitedConn) wait(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-c.closed:
+	}
+}
+
+// bucket is a token bucket holding up to a second's worth of bytes.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// take takes n bytes out of the bucket, refilled at rate bytes per second, and returns
+// how long to wait until they are paid for. A zero rate does not limit.
+func (b *bucket) take(rate int64, n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if rate <= 0 {
+		b.tokens = 0
+		b.last = time.Time{}
+		return 0
+	}
+
+	burst := float64(rate)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*float64(rate), burst)
+	}
+
+	b.last = now
+	b.tokens -= float64(n)
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	b := new(bucket)
+	now := time.Now()
+
+	// A full bucket pays for a second's worth at once.
+	assert.Zero(t, b.take(1000, 1000, now))
+	assert.Equal(t, 500*time.Millisecond, b.take(1000, 500, now))
+
+	// The debt is paid after it was waited for.
+	assert.Zero(t, b.take(1000, 500, now.Add(time.Second)))
+
+	// The bucket holds no more than a second's worth.
+	assert.Equal(t, time.Second, b.take(1000, 2000, now.Add(time.Hour)))
+
+	// No limit takes nothing.
+	assert.Zero(t, b.take(0, 1<<30, now))
+}
+
+func TestBandwidthSchedule(t *testing.T) {
+	t.Parallel()
+
+	b := Bandwidth{
+		Limits: Limits{Upload: 100},
+		Schedule: []BandwidthPeriod{
+			{From: 23 * time.Hour, To: 7 * time.Hour},
+			{From: 12 * time.Hour, To: 13 * time.Hour, Limits: Limits{Upload: 10}},
+		},
+	}
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	assert.Equal(t, Limits{}, b.limits(at(23, 30)))
+	assert.Equal(t, Limits{}, b.limits(at(3, 0)))
+	assert.Equal(t, Limits{Upload: 100}, b.limits(at(7, 0)))
+	assert.Equal(t, Limits{Upload: 10}, b.limits(at(12, 59)))
+	assert.Equal(t, Limits{Upload: 100}, b.limits(at(18, 0)))
+}
+
+func TestLimitedConn(t *testing.T) {
+	t.Parallel()
+
+	b := newBandwidth(Bandwidth{Limits: Limits{PeerUpload: 100_000}})
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	conn := b.conn(client, "user1")
+
+	go io.Copy(io.Discard, server)
+
+	// The first second's worth goes at once, the rest at the rate.
+	start := time.Now()
+	n, err := conn.Write(make([]byte, 200_000))
+	require.NoError(t, err)
+	assert.Equal(t, 200_000, n)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+
+	// The limit changes while running.
+	b.set(Bandwidth{})
+
+	start = time.Now()
+	_, err = conn.Write(make([]byte, 1_000_000))
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// The user's buckets go with its last connection.
+	require.NoError(t, conn.Close())
+	assert.Empty(t, b.peers)
+}
@@ -41,10 +41,20 @@ type Client struct {
 	dialling bool
 	wg       sync.WaitGroup
 	cancel   context.CancelFunc
+	// disconnected is notified when the server connection drops unexpectedly.
+	disconnected chan *disconnect
+	// relogged is set when the server kicks us, the drop that follows is expected.
+	relogged bool
 
 	log zerolog.Logger
 }
 
+// disconnect is a server connection that dropped and the error reading from it.
+type disconnect struct {
+	conn net.Conn
+	err  error
+}
+
 type PierceFirewall struct {
 	*peer.PierceFirewall
 	Conn       net.Conn
@@ -58,48 +68,134 @@ type PeerInit struct {
 }
 
 type Config struct {
-	SoulSeekAddress    string
-	SoulSeekPort       int
-	OwnHostname        string
-	OwnPort            int
-	OwnPortObfuscated  int
-	Username           string
-	Password           string
-	SharedFolders      int
-	SharedFiles        int
-	LogLevel           zerolog.Level
-	Timeout            time.Duration
-	LoginTimeout       time.Duration
-	DownloadFolder     string
-	MaxPeers           int64
+	SoulSeekAddress   string
+	SoulSeekPort      int
+	OwnHostname       string
+	OwnPort           int
+	OwnPortObfuscated int
+	Username          string
+	Password          string
+	// SharedFolders and SharedFiles override the counts we report to the server.
+	// Zero reports the indexed library.
+	SharedFolders int
+	SharedFiles   int
+	LogLevel      zerolog.Level
+	Timeout       time.Duration
+	// LoginTimeout bounds each automatic re-login attempt, see Reconnect.
+	// State.Login itself is bounded by its context.
+	LoginTimeout time.Duration
+	// Reconnect enables re-dialling and logging in again when the server connection drops.
+	Reconnect bool
+	// ReconnectBackoff is the delay before the first reconnect attempt. It doubles after
+	// every failed attempt up to ReconnectMaxBackoff.
+	ReconnectBackoff    time.Duration
+	ReconnectMaxBackoff time.Duration
+	// DownloadFolder is where finished downloads are saved, see DownloadLayout.
+	DownloadFolder string
+	// IncompleteFolder keeps downloads until they finish. Empty is a folder named
+	// incomplete in DownloadFolder.
+	IncompleteFolder string
+	// DownloadLayout is how finished downloads are arranged in DownloadFolder, see LayoutFolder.
+	DownloadLayout DownloadLayout
+	// DownloadCollision decides what happens when a finished download's name is taken,
+	// see CollisionRename.
+	DownloadCollision Collision
+	MaxPeers          int64
+	// UploadTimeout bounds waiting for a downloader to accept an upload and then to tell
+	// the offset it resumes from. Past it the upload fails and frees its slot.
+	UploadTimeout time.Duration
+	// MaxFileConnections is the number of uploads running at once, the rest wait in the
+	// upload queue.
 	MaxFileConnections int64
 	AcceptChildren     bool
 	MaxChildren        int
 	Description        string
 	Picture            []byte
-	Library            string
+	// Library is a folder to share, advertised under its base name. See Shares.
+	Library string
+	// Shares maps share names to local folders, ie. "music" to "/srv/media/music".
+	// Peers only ever see the name, a file in it is advertised as @@music\Artist\track.flac.
+	Shares map[string]string
+	// AutoRespond answers incoming searches from the share index instead of sending
+	// them to State.Incoming.
+	AutoRespond bool
+	// MaxSearchResults caps the number of files we answer a search with, zero does not.
+	MaxSearchResults int
+	// MaxSessionResults ends a SearchSession once it collected that many results, zero
+	// does not.
+	MaxSessionResults int
+	// StateFolder is where the client keeps its state between runs, ie. the share index
+	// cache and the download queue. Empty keeps nothing on disk.
+	StateFolder string
+	// MaxDownloads and MaxDownloadsPerUser cap how many files the DownloadManager
+	// downloads at once, in total and from a single user. Zero does not cap.
+	MaxDownloads        int
+	MaxDownloadsPerUser int
+	// DownloadRetries is how many times the DownloadManager retries a failed download.
+	// DownloadRetryBackoff is the delay before the first retry, it doubles after every
+	// failed attempt up to DownloadRetryMaxBackoff.
+	DownloadRetries         int
+	DownloadRetryBackoff    time.Duration
+	DownloadRetryMaxBackoff time.Duration
+	// PlaceInQueueInterval is how often a queued download asks the peer for its place in
+	// the queue. Zero asks only once.
+	PlaceInQueueInterval time.Duration
+	// MaxSourceQueue and SourceQueueTimeout move a queued download on to the next of
+	// File.Sources once the user puts it further back in its queue than MaxSourceQueue,
+	// or keeps it queued for longer than SourceQueueTimeout. Zero does not move it.
+	MaxSourceQueue     int
+	SourceQueueTimeout time.Duration
+	// MaxQueuedFilesPerUser and MaxQueuedMegabytesPerUser cap how much a user may queue
+	// for upload, more is denied with peer.ErrTooManyFiles or peer.ErrTooManyMegabytes.
+	// Privileged users are not capped. Zero does not cap.
+	MaxQueuedFilesPerUser     int
+	MaxQueuedMegabytesPerUser int
+	// Bandwidth limits the rate of uploads and downloads, in total and per user, see
+	// State.SetBandwidth to change it while running.
+	Bandwidth Bandwidth
+	// Ranking weighs search results for SearchSession.Best and SearchSession.BestFolder.
+	Ranking Ranking
 }
 
 // DefaultConfig returns a default configuration for the client.
 func DefaultConfig() *Config {
 	return &Config{
-		SoulSeekAddress:    "server.slsknet.org",
-		SoulSeekPort:       2242,
-		OwnHostname:        "localhost",
-		OwnPort:            2234,
-		OwnPortObfuscated:  2235,
-		Username:           gonanoid.MustGenerate("soulseek", 7),
-		Password:           gonanoid.MustGenerate("0123456789qwertyuiop", 10),
-		LogLevel:           zerolog.Disabled,
-		Timeout:            2 * time.Second,
-		LoginTimeout:       3 * time.Second,
-		DownloadFolder:     os.TempDir(),
-		MaxPeers:           100,
-		MaxFileConnections: 20,
-		AcceptChildren:     true,
-		MaxChildren:        50,
-		Description:        "Soul client",
-		Picture:            adorable.Random(),
+		SoulSeekAddress:           "server.slsknet.org",
+		SoulSeekPort:              2242,
+		OwnHostname:               "localhost",
+		OwnPort:                   2234,
+		OwnPortObfuscated:         2235,
+		Username:                  gonanoid.MustGenerate("soulseek", 7),
+		Password:                  gonanoid.MustGenerate("0123456789qwertyuiop", 10),
+		LogLevel:                  zerolog.Disabled,
+		Timeout:                   2 * time.Second,
+		LoginTimeout:              3 * time.Second,
+		Reconnect:                 true,
+		ReconnectBackoff:          time.Second,
+		ReconnectMaxBackoff:       5 * time.Minute,
+		DownloadFolder:            os.TempDir(),
+		DownloadLayout:            LayoutFolder,
+		DownloadCollision:         CollisionRename,
+		MaxPeers:                  100,
+		MaxFileConnections:        20,
+		UploadTimeout:             time.Minute,
+		AcceptChildren:            true,
+		MaxChildren:               50,
+		MaxSearchResults:          100,
+		MaxSessionResults:         1000,
+		MaxDownloads:              10,
+		MaxDownloadsPerUser:       2,
+		DownloadRetries:           5,
+		DownloadRetryBackoff:      30 * time.Second,
+		DownloadRetryMaxBackoff:   10 * time.Minute,
+		PlaceInQueueInterval:      5 * time.Minute,
+		MaxSourceQueue:            50,
+		SourceQueueTimeout:        10 * time.Minute,
+		MaxQueuedFilesPerUser:     500,
+		MaxQueuedMegabytesPerUser: 5000,
+		Ranking:                   Ranking{Quality: 3, FreeSlot: 2, Speed: 1, Queue: 2, Name: 3},
+		Description:               "Soul client",
+		Picture:                   adorable.Random(),
 	}
 }
 
@@ -118,6 +214,7 @@ func New(conf *Config) (*Client, error) {
 	// Init all necessary maps and channels.
 	c.Firewall = make(chan *PierceFirewall)
 	c.Init = make(chan *PeerInit)
+	c.disconnected = make(chan *disconnect, 1)
 
 	c.relaysInit()
 
@@ -180,6 +277,19 @@ func (c *Client) Dial(ctx context.Context, cancel context.CancelFunc) error {
 	return nil
 }
 
+// Reconnect replaces the server connection and resumes reading from it.
+// Peer listeners are left untouched.
+func (c *Client) Reconnect(ctx context.Context) error {
+	err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	go c.read(ctx)
+
+	return nil
+}
+
 // Conn returns the current connection to the server.
 func (c *Client) Conn() net.Conn {
 	c.mu.RLock()
@@ -238,12 +348,17 @@ func (c *Client) dial() error {
 
 	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%v", c.config.SoulSeekAddress, c.config.SoulSeekPort))
 	if err != nil {
+		c.mu.Lock()
+		c.dialling = false
+		c.mu.Unlock()
+
 		return err
 	}
 
 	c.mu.Lock()
 	c.conn = conn
 	c.dialling = false
+	c.relogged = false
 	c.mu.Unlock()
 
 	c.log.Debug().Msg("dialling to server successful once")
@@ -260,9 +375,12 @@ func (c *Client) listenObfuscated(ctx context.Context) {
 			return
 
 		default:
+			// Accept blocks, it must not hold the lock meanwhile.
 			c.mu.RLock()
-			conn, err := c.listenerObfuscated.Accept()
+			listener := c.listenerObfuscated
 			c.mu.RUnlock()
+
+			conn, err := listener.Accept()
 			if err != nil {
 				co.Warn().Err(err).Msg("accept TCP")
 				continue
@@ -318,9 +436,12 @@ func (c *Client) listen(ctx context.Context) {
 			return
 
 		default:
+			// Accept blocks, it must not hold the lock meanwhile.
 			c.mu.RLock()
-			conn, err := c.listener.Accept()
+			listener := c.listener
 			c.mu.RUnlock()
+
+			conn, err := listener.Accept()
 			if err != nil {
 				c.log.Warn().Err(err).Msg("accept TCP")
 				continue
@@ -383,8 +504,43 @@ func (c *Client) read(ctx context.Context) {
 		default:
 			r, _, code, err := server.Read(conn)
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				c.mu.RLock()
+				replaced, relogged := c.conn != conn, c.relogged
+				c.mu.RUnlock()
+
+				// The connection was replaced by a new dial, or the server kicked us
+				// on purpose. Either way this is not a connection failure.
+				if replaced || relogged {
+					return
+				}
+
 				c.log.Err(err).Msg("server read")
-				continue
+
+				// The latest drop replaces one nobody handled yet.
+				for {
+					select {
+					case c.disconnected <- &disconnect{conn: conn, err: err}:
+						return
+
+					default:
+						select {
+						case <-c.disconnected:
+						default:
+						}
+					}
+				}
+			}
+
+			// The server closes the connection right after Relogged. Mark it before
+			// the message is handled so the drop is not mistaken for a failure.
+			if code == server.CodeRelogged {
+				c.mu.Lock()
+				c.relogged = true
+				c.mu.Unlock()
 			}
 
 			// Send the message to the deserialization queue.
@@ -536,7 +692,8 @@ func (c *Client) read(ctx context.Context) {
 				case server.CodeLogin:
 					m := new(server.Login)
 					err := m.Deserialize(r)
-					if err != nil && !errors.Is(err, io.EOF) {
+					// Failed logins are relayed too, the failure is in m.Reason.
+					if err != nil && !errors.Is(err, io.EOF) && m.Reason == nil {
 						c.log.Err(err).Msg("login deserialize")
 						return
 					}
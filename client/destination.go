@@ -0,0 +1,215 @@
+package client
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// incompleteFolder is the folder in Config.DownloadFolder unfinished downloads are kept
+// in when Config.IncompleteFolder is empty.
+const incompleteFolder = "incomplete"
+
+// DownloadLayout is how finished downloads are arranged in Config.DownloadFolder.
+type DownloadLayout string
+
+const (
+	// LayoutFolder keeps the folder the file is in on the peer, ie. @@music\Artist\Album\track.flac
+	// is saved as Album/track.flac. Files of a folder download keep the structure below the
+	// folder, see State.FolderContents.
+	LayoutFolder DownloadLayout = "folder"
+	// LayoutUser rebuilds the whole remote path under the peer's username, ie. the file
+	// above is saved as username/music/Artist/Album/track.flac.
+	LayoutUser DownloadLayout = "user"
+	// LayoutFlat saves every file straight in Config.DownloadFolder.
+	LayoutFlat DownloadLayout = "flat"
+)
+
+// Collision decides what happens when a finished download's name is already taken.
+type Collision string
+
+const (
+	// CollisionRename saves the download numbered next to the existing file, ie. track (1).flac.
+	CollisionRename Collision = "rename"
+	// CollisionOverwrite replaces the existing file.
+	CollisionOverwrite Collision = "overwrite"
+	// CollisionSkip keeps the existing file and discards the download.
+	CollisionSkip Collision = "skip"
+)
+
+// incompletePath returns where f is kept until it is downloaded. The name is unique per
+// user and remote path, so a download only ever resumes from its own partial file.
+func incompletePath(config *Config, f *File) string {
+	folder := config.IncompleteFolder
+	if folder == "" {
+		folder = filepath.Join(config.DownloadFolder, incompleteFolder)
+	}
+
+	sum := sha1.Sum([]byte(f.Username + "\x00" + f.Name))
+
+	return filepath.Join(folder, hex.EncodeToString(sum[:8])+"-"+sanitize(virtualBase(f.Name)))
+}
+
+// destination returns where f is saved once downloaded, according to Config.DownloadLayout.
+func destination(config *Config, f *File) string {
+	elements := remote(f.Name)
+
+	switch config.DownloadLayout {
+	case LayoutUser:
+		elements = append([]string{sanitize(f.Username)}, elements...)
+
+	case LayoutFlat:
+		elements = elements[len(elements)-1:]
+
+	default:
+		elements = elements[below(elements, f.Folder):]
+	}
+
+	return filepath.Join(append([]string{config.DownloadFolder}, elements...)...)
+}
+
+// below returns the index of the folder elements are saved under: the folder a folder
+// download started from, if elements are in it, the folder the file is in otherwise.
+func below(elements []string, folder string) int {
+	if folder != "" {
+		parent := remote(folder)
+		if len(parent) < len(elements) && slices.Equal(parent, elements[:len(parent)]) {
+			return len(parent) - 1
+		}
+	}
+
+	return max(len(elements)-2, 0)
+}
+
+// remote splits a path a peer sent us into elements that are safe to use locally. The
+// share prefix and drive letters are dropped, as are elements that would leave the
+// download folder.
+func remote(name string) []string {
+	var elements []string
+	for _, element := range strings.FieldsFunc(name, func(r rune) bool { return r == '\\' || r == '/' }) {
+		element = strings.TrimPrefix(element, virtualPrefix)
+		element = strings.TrimSuffix(element, ":")
+
+		element = sanitize(element)
+		if element == "" || element == "." || element == ".." {
+			continue
+		}
+
+		elements = append(elements, element)
+	}
+
+	if len(elements) == 0 {
+		return []string{"unnamed"}
+	}
+
+	return elements
+}
+
+// sanitize replaces characters that are not valid in file names on common filesystems.
+func sanitize(element string) string {
+	element = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+
+		return r
+	}, element)
+
+	return strings.TrimSpace(element)
+}
+
+// move moves the finished download incomplete to dest, handling a taken dest as
+// collision says. It returns where the file ended up.
+func move(incomplete, dest string, collision Collision) (string, error) {
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = os.Stat(dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+
+	case err != nil:
+		return "", err
+
+	case collision == CollisionSkip:
+		return dest, os.Remove(incomplete)
+
+	case collision == CollisionOverwrite:
+
+	default:
+		dest, err = free(dest)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = os.Rename(incomplete, dest)
+	if err == nil {
+		return dest, nil
+	}
+
+	// The incomplete folder may be on another filesystem.
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return "", err
+	}
+
+	err = copyFile(incomplete, dest)
+	if err != nil {
+		return "", err
+	}
+
+	return dest, os.Remove(incomplete)
+}
+
+// free returns the first numbered variant of path that does not exist.
+func free(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+
+		_, err := os.Stat(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// copyFile copies src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestination(t *testing.T) {
+	t.Parallel()
+
+	f := &File{Username: "user", File: &peer.File{Name: `@@music\Artist\Album\track.flac`}}
+
+	tests := map[DownloadLayout]string{
+		LayoutFolder: filepath.Join("downloads", "Album", "track.flac"),
+		LayoutUser:   filepath.Join("downloads", "user", "music", "Artist", "Album", "track.flac"),
+		LayoutFlat:   filepath.Join("downloads", "track.flac"),
+		"":           filepath.Join("downloads", "Album", "track.flac"),
+	}
+
+	for layout, expected := range tests {
+		config := &Config{DownloadFolder: "downloads", DownloadLayout: layout}
+		assert.Equal(t, expected, destination(config, f), layout)
+	}
+
+	// Files of a folder download keep their place below the folder.
+	config := &Config{DownloadFolder: "downloads", DownloadLayout: LayoutFolder}
+	f = &File{Username: "user", Folder: `@@music\Artist`, File: &peer.File{Name: `@@music\Artist\Album\CD1\track.flac`}}
+	assert.Equal(t, filepath.Join("downloads", "Artist", "Album", "CD1", "track.flac"), destination(config, f))
+
+	f.Folder = `@@other\Artist`
+	assert.Equal(t, filepath.Join("downloads", "CD1", "track.flac"), destination(config, f))
+}
+
+func TestRemote(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]string{
+		`@@music\Artist\track.flac`:          {"music", "Artist", "track.flac"},
+		`C:\Users\me\Music\track.flac`:       {"C", "Users", "me", "Music", "track.flac"},
+		`/home/me/music/track.flac`:          {"home", "me", "music", "track.flac"},
+		`@@music\..\..\..\etc\passwd`:        {"music", "etc", "passwd"},
+		`@@music\Artist: Live?\track<1>.mp3`: {"music", "Artist_ Live_", "track_1_.mp3"},
+		`\\`:                                 {"unnamed"},
+	}
+
+	for name, expected := range tests {
+		assert.Equal(t, expected, remote(name), name)
+	}
+}
+
+func TestIncompletePath(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{DownloadFolder: "downloads"}
+
+	a := incompletePath(config, &File{Username: "user1", File: &peer.File{Name: `@@music\a\track.flac`}})
+	b := incompletePath(config, &File{Username: "user2", File: &peer.File{Name: `@@music\a\track.flac`}})
+	c := incompletePath(config, &File{Username: "user1", File: &peer.File{Name: `@@music\b\track.flac`}})
+
+	assert.Equal(t, filepath.Join("downloads", incompleteFolder), filepath.Dir(a))
+	assert.Contains(t, filepath.Base(a), "track.flac")
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, a, c)
+
+	config.IncompleteFolder = "partial"
+	assert.Equal(t, "partial", filepath.Dir(incompletePath(config, &File{Username: "user1", File: &peer.File{Name: "track.flac"}})))
+}
+
+func TestMove(t *testing.T) {
+	t.Parallel()
+
+	tests := map[Collision]struct {
+		path    string
+		content string
+	}{
+		CollisionRename:    {path: "track (2).flac", content: "new"},
+		CollisionOverwrite: {path: "track.flac", content: "new"},
+		CollisionSkip:      {path: "track.flac", content: "old"},
+	}
+
+	for collision, expected := range tests {
+		t.Run(string(collision), func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "album", "track.flac")
+			incomplete := filepath.Join(dir, "incomplete")
+
+			require.NoError(t, os.MkdirAll(filepath.Dir(dest), 0755))
+			require.NoError(t, os.WriteFile(dest, []byte("old"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "album", "track (1).flac"), []byte("older"), 0644))
+			require.NoError(t, os.WriteFile(incomplete, []byte("new"), 0644))
+
+			path, err := move(incomplete, dest, collision)
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(dir, "album", expected.path), path)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected.content, string(content))
+
+			_, err = os.Stat(incomplete)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
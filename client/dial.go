@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/file"
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/server"
+)
+
+// dialTimeout bounds each way of opening a P connection, see dial.
+const dialTimeout = 30 * time.Second
+
+// ErrNoConnection is returned when we can neither connect to a peer nor have it connect to us.
+var ErrNoConnection = errors.New("no connection")
+
+// open returns username's peer with an open P connection, dialling it if there is none.
+func (s *State) open(ctx context.Context, username string) (*Peer, error) {
+	s.mu.RLock()
+	p, found := s.peers[username]
+	s.mu.RUnlock()
+
+	if found && p.connected() {
+		return p, nil
+	}
+
+	return s.dial(ctx, username, soul.NewToken())
+}
+
+// dial opens a P connection to username. It connects to the address the server has for
+// the peer and, if that fails, asks the server to have the peer connect to us instead,
+// waiting for the peer's PierceFirewall with token.
+func (s *State) dial(ctx context.Context, username string, token soul.Token) (*Peer, error) {
+	addressCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	address, err := s.address(addressCtx, username)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("%w: peer address: %w", ErrNoPeer, err)
+	}
+
+	port := address.Port
+	obfuscated := address.ObfuscatedPort != 0
+	if obfuscated {
+		port = address.ObfuscatedPort
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%v", address.IP.String(), port))
+	if err == nil {
+		p := s.add(username, address)
+		s.initializers(ctx, peer.ConnectionType, p, conn, obfuscated, s.log)
+
+		_, err = peer.Write(conn, &peer.PeerInit{
+			Username:       s.client.config.Username,
+			ConnectionType: peer.ConnectionType,
+		}, obfuscated)
+		if err != nil {
+			return nil, err
+		}
+
+		return p, nil
+	}
+
+	s.log.Debug().Err(err).Str("username", username).Msg("direct connection")
+
+	firewall, err := s.pierce(ctx, username, token, peer.ConnectionType)
+	if err != nil {
+		return nil, err
+	}
+
+	p := s.add(username, address)
+	s.initializers(ctx, peer.ConnectionType, p, firewall.Conn, firewall.Obfuscated, s.log)
+
+	return p, nil
+}
+
+// pierce asks the server to have username open a connection of connType to us and
+// waits for the peer's PierceFirewall with token.
+func (s *State) pierce(ctx context.Context, username string, token soul.Token, connType soul.ConnectionType) (*PierceFirewall, error) {
+	pierced := make(chan *PierceFirewall, 1)
+
+	s.mu.Lock()
+	s.pierced[token] = pierced
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pierced, token)
+		s.mu.Unlock()
+	}()
+
+	cant := s.client.Relays.CantConnectToPeer.Listener(1)
+	defer cant.Close()
+
+	_, err := server.Write(s.client.Conn(), &server.ConnectToPeer{
+		Token:    token,
+		Username: username,
+		Type:     connType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Debug().Str("username", username).Msg("waiting for peer to connect")
+
+	timeout := time.NewTimer(dialTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case <-timeout.C:
+			return nil, fmt.Errorf("%w: %s", ErrNoConnection, username)
+
+		case m := <-cant.Ch():
+			if m.Token == token {
+				return nil, fmt.Errorf("%w: %s", ErrNoConnection, username)
+			}
+
+		case firewall := <-pierced:
+			return firewall, nil
+		}
+	}
+}
+
+// fileConn opens an F connection to p for the upload with token and sends the
+// TransferInit on it. Like dial it connects directly first and, if that fails, asks the
+// peer to connect to us instead.
+func (s *State) fileConn(ctx context.Context, p *Peer, token soul.Token) (net.Conn, error) {
+	conn, err := s.dialFile(ctx, p)
+	if err != nil {
+		s.log.Debug().Err(err).Str("username", p.username).Msg("direct file connection")
+
+		firewall, err := s.pierce(ctx, p.username, soul.NewToken(), file.ConnectionType)
+		if err != nil {
+			return nil, err
+		}
+
+		conn = firewall.Conn
+	}
+
+	_, err = file.Write(conn, &file.TransferInit{Token: token})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// dialFile connects to p's listening port and introduces us on an F connection.
+func (s *State) dialFile(ctx context.Context, p *Peer) (net.Conn, error) {
+	// Peers that connected to us directly have not told us their listening port.
+	ip, port := p.address()
+	if ip == nil {
+		addressCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		address, err := s.address(addressCtx, p.username)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%w: peer address: %w", ErrNoPeer, err)
+		}
+
+		s.add(p.username, address)
+		ip, port = address.IP, address.Port
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%v", ip.String(), port))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = peer.Write(conn, &peer.PeerInit{
+		Username:       s.client.config.Username,
+		ConnectionType: file.ConnectionType,
+	}, false)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// firewall hands a peer's PierceFirewall to the dial waiting for it. It reports whether
+// anyone was waiting.
+func (s *State) firewall(firewall *PierceFirewall) bool {
+	s.mu.RLock()
+	pierced, found := s.pierced[firewall.Token]
+	s.mu.RUnlock()
+
+	if !found {
+		return false
+	}
+
+	select {
+	case pierced <- firewall:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// add returns username's peer, adding it if it is new, at address.
+func (s *State) add(username string, address *server.GetPeerAddress) *Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, found := s.peers[username]
+	if !found {
+		p = NewPeer(s.client.config, &peer.PeerInit{
+			Username:       username,
+			ConnectionType: peer.ConnectionType,
+		})
+
+		s.peers[username] = p
+	}
+
+	p.setAddress(address.IP, address.Port, address.ObfuscatedPort)
+
+	return p
+}
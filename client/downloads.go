@@ -0,0 +1,410 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+)
+
+const (
+	// downloadsFile is the name of the download queue file in Config.StateFolder.
+	downloadsFile = "downloads.gob"
+	// downloadEvents is the buffer size of DownloadManager.Events. Events are dropped if
+	// nobody reads them.
+	downloadEvents = 16
+	// scheduleInterval is how often the DownloadManager looks for retries that are due.
+	scheduleInterval = time.Second
+)
+
+// Download is a file in the DownloadManager's queue.
+type Download struct {
+	*File
+	// State is the state of the latest attempt, TransferRequested while the download
+	// waits for a slot.
+	State TransferState
+	// Position, Bytes and Rate are the latest progress, see TransferEvent.
+	Position int
+	Bytes    int64
+	Rate     int64
+	// Attempts is the number of times the download was started.
+	Attempts int
+	// Path is where the file was saved once completed.
+	Path string
+	// Source is the user the latest attempt downloads from, see File.Sources.
+	Source string
+	// Retry is when a failed download is started again, zero if it is not.
+	Retry time.Time
+	// Err is the reason the latest attempt failed.
+	Err error
+}
+
+// savedDownload is a Download as saved in the queue file.
+type savedDownload struct {
+	File     File
+	State    TransferState
+	Attempts int
+	Path     string
+	Retry    time.Time
+	Err      string
+}
+
+// job is a queued download. While it runs, cancel stops it.
+type job struct {
+	Download
+	cancel  context.CancelFunc
+	running bool
+}
+
+// pending reports whether the job waits to be started.
+func (j *job) pending(now time.Time) bool {
+	switch {
+	case j.running:
+		return false
+
+	case j.State == TransferCompleted:
+		return false
+
+	case j.State == TransferFailed:
+		return !j.Retry.IsZero() && !now.Before(j.Retry)
+
+	default:
+		return true
+	}
+}
+
+// DownloadManager downloads queued files, at most Config.MaxDownloads at once and
+// Config.MaxDownloadsPerUser from the same user. Failed downloads are retried with
+// backoff up to Config.DownloadRetries times. The queue is kept in Config.StateFolder,
+// so that unfinished downloads carry on after a restart, resuming what is on disk.
+type DownloadManager struct {
+	// Events reports every change of a queued download. Events are dropped if nobody
+	// reads them, Downloads always returns the latest state.
+	Events chan *Download
+
+	state *State
+	jobs  []*job
+	mu    sync.Mutex
+	wake  chan struct{}
+}
+
+// NewDownloadManager returns a DownloadManager downloading with s, with the queue saved
+// in Config.StateFolder, if any.
+func NewDownloadManager(s *State) (*DownloadManager, error) {
+	m := &DownloadManager{
+		Events: make(chan *Download, downloadEvents),
+		state:  s,
+		wake:   make(chan struct{}, 1),
+	}
+
+	var saved []savedDownload
+	_, err := load(s.client.config.StateFolder, downloadsFile, &saved)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range saved {
+		f := d.File
+		j := &job{Download: Download{
+			File:     &f,
+			State:    d.State,
+			Attempts: d.Attempts,
+			Path:     d.Path,
+			Retry:    d.Retry,
+		}}
+
+		if d.Err != "" {
+			j.Err = peer.Reason(d.Err)
+		}
+
+		// Attempts cut short by the restart start over.
+		if !j.State.Final() || j.State == TransferCancelled {
+			j.State = TransferRequested
+		}
+
+		m.jobs = append(m.jobs, j)
+	}
+
+	return m, nil
+}
+
+// Add queues f for download. Adding a file that is already queued does nothing, a
+// finished one is downloaded again.
+func (m *DownloadManager) Add(f *File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j := m.find(f.Username, f.Name)
+	switch {
+	case j == nil:
+		j = &job{Download: Download{File: f, State: TransferRequested}}
+		m.jobs = append(m.jobs, j)
+
+	case j.running || !j.State.Final():
+		return nil
+
+	default:
+		j.Download = Download{File: f, State: TransferRequested}
+	}
+
+	m.event(j)
+	m.schedule()
+
+	return m.save()
+}
+
+// want queues f for download unless it is queued already, finished or not.
+func (m *DownloadManager) want(f *File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.find(f.Username, f.Name) != nil {
+		return nil
+	}
+
+	j := &job{Download: Download{File: f, State: TransferRequested}}
+	m.jobs = append(m.jobs, j)
+
+	m.event(j)
+	m.schedule()
+
+	return m.save()
+}
+
+// Remove drops the download of filename from username, cancelling it if it is running.
+// What was downloaded so far stays on disk.
+func (m *DownloadManager) Remove(username, filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j := m.find(username, filename)
+	if j == nil {
+		return nil
+	}
+
+	if j.cancel != nil {
+		j.cancel()
+	}
+
+	m.jobs = slices.DeleteFunc(m.jobs, func(q *job) bool { return q == j })
+
+	return m.save()
+}
+
+// Downloads returns the queued downloads, in the order they were added.
+func (m *DownloadManager) Downloads() []Download {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	downloads := make([]Download, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		downloads = append(downloads, j.Download)
+	}
+
+	return downloads
+}
+
+// Run starts queued downloads as slots free up, until ctx is done. Running downloads
+// are cancelled then and Run returns once they stopped, with the queue saved.
+func (m *DownloadManager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		m.mu.Lock()
+		for _, j := range m.startable(time.Now()) {
+			var jobCtx context.Context
+			jobCtx, j.cancel = context.WithCancel(ctx)
+			j.running = true
+			j.Attempts++
+			j.Retry = time.Time{}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.run(jobCtx, j)
+			}()
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// startable returns the pending jobs that fit in the free download slots.
+func (m *DownloadManager) startable(now time.Time) []*job {
+	config := m.state.client.config
+
+	var running int
+	users := make(map[string]int)
+	for _, j := range m.jobs {
+		if j.running {
+			running++
+			users[j.Username]++
+		}
+	}
+
+	var start []*job
+	for _, j := range m.jobs {
+		if config.MaxDownloads > 0 && running >= config.MaxDownloads {
+			break
+		}
+
+		if !j.pending(now) {
+			continue
+		}
+
+		if config.MaxDownloadsPerUser > 0 && users[j.Username] >= config.MaxDownloadsPerUser {
+			continue
+		}
+
+		running++
+		users[j.Username]++
+		start = append(start, j)
+	}
+
+	return start
+}
+
+// run downloads j once and records how it went.
+func (m *DownloadManager) run(ctx context.Context, j *job) {
+	t, err := m.state.Download(ctx, j.File)
+	if err != nil {
+		m.update(j, &TransferEvent{State: TransferFailed, Err: err})
+		return
+	}
+
+	for e := range t.Events {
+		m.update(j, e)
+	}
+}
+
+// update records e as the latest state of j. Once the attempt is over the job's slot
+// is freed and, if it failed with a reason worth retrying, a retry is scheduled.
+func (m *DownloadManager) update(j *job, e *TransferEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := j.State != e.State
+
+	j.State = e.State
+	j.Position = e.Position
+	j.Rate = e.Rate
+	if e.Bytes > 0 {
+		j.Bytes = e.Bytes
+	}
+
+	if e.Err != nil {
+		j.Err = e.Err
+	}
+
+	if e.Path != "" {
+		j.Path = e.Path
+	}
+
+	if e.Source != "" {
+		j.Source = e.Source
+	}
+
+	m.event(j)
+
+	if !e.State.Final() {
+		if changed {
+			m.logSave(m.save())
+		}
+
+		return
+	}
+
+	j.cancel()
+	j.cancel = nil
+	j.running = false
+
+	if e.State == TransferFailed && retryable(e.Err) && j.Attempts <= m.state.client.config.DownloadRetries {
+		config := m.state.client.config
+		j.Retry = time.Now().Add(backoff(config.DownloadRetryBackoff, config.DownloadRetryMaxBackoff, j.Attempts))
+	}
+
+	m.state.log.Debug().Str("username", j.Username).Str("filename", j.Name).Str("state", string(j.State)).
+		Err(j.Err).Time("retry", j.Retry).Msg("download")
+
+	m.logSave(m.save())
+	m.schedule()
+}
+
+// retryable reports whether a download that failed because of err may succeed if it is
+// tried again. Files the peer does not share, or does not share with us, never will.
+func retryable(err error) bool {
+	return !errors.Is(err, peer.ErrFileNotShared) && !errors.Is(err, peer.ErrBanned) &&
+		!errors.Is(err, peer.ErrCancelled)
+}
+
+// find returns the job of filename from username, nil if there is none.
+func (m *DownloadManager) find(username, filename string) *job {
+	for _, j := range m.jobs {
+		if j.Username == username && j.Name == filename {
+			return j
+		}
+	}
+
+	return nil
+}
+
+// schedule wakes Run up to start whatever fits in the free slots.
+func (m *DownloadManager) schedule() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// event sends a copy of j to Events without blocking.
+func (m *DownloadManager) event(j *job) {
+	d := j.Download
+
+	select {
+	case m.Events <- &d:
+	default:
+	}
+}
+
+// save writes the queue to Config.StateFolder. It must be called with mu held.
+func (m *DownloadManager) save() error {
+	saved := make([]savedDownload, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		d := savedDownload{
+			File:     *j.File,
+			State:    j.State,
+			Attempts: j.Attempts,
+			Path:     j.Path,
+			Retry:    j.Retry,
+		}
+
+		if j.Err != nil {
+			d.Err = j.Err.Error()
+		}
+
+		saved = append(saved, d)
+	}
+
+	return save(m.state.client.config.StateFolder, downloadsFile, saved)
+}
+
+// logSave logs a failure to save the queue, which is not worth stopping a download for.
+func (m *DownloadManager) logSave(err error) {
+	if err != nil {
+		m.state.log.Warn().Err(err).Msg("save downloads")
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadManager(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	results, err := state1.Search(ctx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	var f *File
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "search timeout")
+
+	case f = <-results:
+	}
+
+	m, err := NewDownloadManager(state1)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Add(f))
+	require.NoError(t, m.Add(&File{
+		Username: f.Username,
+		Token:    soul.NewToken(),
+		File:     &peer.File{Name: `@@unknown\secret.txt`, Size: 6},
+	}))
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		m.Run(runCtx)
+		close(done)
+	}()
+
+	finished := make(map[string]Download)
+	require.Eventually(t, func() bool {
+		for _, d := range m.Downloads() {
+			if d.State.Final() {
+				finished[d.Name] = d
+			}
+		}
+
+		return len(finished) == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	stop()
+	<-done
+
+	assert.Equal(t, TransferCompleted, finished[f.Name].State)
+	assert.Equal(t, 1, finished[f.Name].Attempts)
+
+	// Files the peer does not share are not retried.
+	denied := finished[`@@unknown\secret.txt`]
+	assert.Equal(t, TransferFailed, denied.State)
+	assert.ErrorIs(t, denied.Err, peer.ErrFileNotShared)
+	assert.True(t, denied.Retry.IsZero())
+
+	expected, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+	require.NoError(t, err)
+
+	downloaded, err := os.ReadFile(finished[f.Name].Path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, downloaded)
+
+	// The queue survives a restart.
+	m, err = NewDownloadManager(state1)
+	require.NoError(t, err)
+
+	downloads := m.Downloads()
+	require.Len(t, downloads, 2)
+	assert.Equal(t, f.Name, downloads[0].Name)
+	assert.Equal(t, TransferCompleted, downloads[0].State)
+	assert.ErrorIs(t, downloads[1].Err, peer.ErrFileNotShared)
+
+	require.NoError(t, m.Remove(f.Username, f.Name))
+	assert.Len(t, m.Downloads(), 1)
+}
+
+func TestDownloadManagerRestart(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.StateFolder = t.TempDir()
+	state := &State{client: &Client{config: config}}
+
+	file := func(username, name string) File {
+		return File{Username: username, File: &peer.File{Name: name, Size: 100}}
+	}
+
+	retry := time.Now().Add(time.Hour)
+	err := save(config.StateFolder, downloadsFile, []savedDownload{
+		{File: file("user1", "a"), State: TransferInProgress, Attempts: 1},
+		{File: file("user1", "b"), State: TransferFailed, Attempts: 1, Retry: retry, Err: peer.ErrTooManyFiles.Error()},
+		{File: file("user2", "c"), State: TransferCancelled, Attempts: 2},
+		{File: file("user2", "d"), State: TransferCompleted, Attempts: 1},
+	})
+	require.NoError(t, err)
+
+	m, err := NewDownloadManager(state)
+	require.NoError(t, err)
+
+	downloads := m.Downloads()
+	require.Len(t, downloads, 4)
+	assert.Equal(t, TransferRequested, downloads[0].State)
+	assert.Equal(t, TransferFailed, downloads[1].State)
+	assert.ErrorIs(t, downloads[1].Err, peer.ErrTooManyFiles)
+	assert.Equal(t, TransferRequested, downloads[2].State)
+	assert.Equal(t, TransferCompleted, downloads[3].State)
+
+	// Interrupted downloads start again, the failed one once its retry is due.
+	assert.Len(t, m.startable(time.Now()), 2)
+	assert.Len(t, m.startable(retry), 3)
+}
+
+func TestDownloadManagerSlots(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.MaxDownloads = 3
+	config.MaxDownloadsPerUser = 2
+
+	m, err := NewDownloadManager(&State{client: &Client{config: config}})
+	require.NoError(t, err)
+
+	for _, f := range []struct{ username, name string }{
+		{"user1", "a"}, {"user1", "b"}, {"user1", "c"}, {"user2", "d"}, {"user3", "e"},
+	} {
+		require.NoError(t, m.Add(&File{Username: f.username, File: &peer.File{Name: f.name}}))
+	}
+
+	var names []string
+	for _, j := range m.startable(time.Now()) {
+		names = append(names, j.Name)
+	}
+
+	assert.Equal(t, []string{"a", "b", "d"}, names)
+
+	// A running download holds its slot.
+	m.jobs[0].running = true
+	m.jobs[3].running = true
+
+	names = nil
+	for _, j := range m.startable(time.Now()) {
+		names = append(names, j.Name)
+	}
+
+	assert.Equal(t, []string{"b"}, names)
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, retryable(peer.ErrFileReadError))
+	assert.True(t, retryable(ErrNoPeer))
+	assert.False(t, retryable(peer.ErrFileNotShared))
+	assert.False(t, retryable(peer.ErrBanned))
+}
@@ -0,0 +1,430 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bh90210/soul/peer"
+)
+
+// ErrFilter is returned for a filter expression that does not parse.
+var ErrFilter = errors.New("invalid filter")
+
+// Filter picks search results, see ParseFilter.
+type Filter struct {
+	conditions []condition
+}
+
+// condition is a term of a filter, match reports whether a result passes it.
+type condition struct {
+	negate bool
+	match  func(*File) bool
+}
+
+// field is a number a result can be compared on. value reports false if the result does
+// not have it, ie. a file without a bitrate attribute. units are the suffixes the number
+// may be given with, and what they multiply it by.
+type field struct {
+	value func(*File) (float64, bool)
+	units map[string]float64
+}
+
+var (
+	sizeUnits = map[string]float64{
+		"b":  1,
+		"kb": 1 << 10, "k": 1 << 10, "kib": 1 << 10,
+		"mb": 1 << 20, "m": 1 << 20, "mib": 1 << 20,
+		"gb": 1 << 30, "g": 1 << 30, "gib": 1 << 30,
+	}
+	secondUnits = map[string]float64{"s": 1, "m": 60, "min": 60, "h": 3600}
+)
+
+// fields are the numbers ParseFilter compares.
+var fields = map[string]field{
+	"bitrate":    {value: attribute(peer.Bitrate)},
+	"duration":   {value: attribute(peer.Duration), units: secondUnits},
+	"samplerate": {value: attribute(peer.SampleRate)},
+	"bitdepth":   {value: attribute(peer.BitDepth)},
+	"size": {value: func(f *File) (float64, bool) {
+		return float64(f.Size), true
+	}, units: sizeUnits},
+	"speed": {value: func(f *File) (float64, bool) {
+		return float64(f.AverageSpeed), true
+	}, units: sizeUnits},
+	"queue": {value: func(f *File) (float64, bool) {
+		return float64(f.Queue), true
+	}},
+}
+
+// flags are the yes or no properties of a result ParseFilter knows.
+var flags = map[string]func(*File) bool{
+	"freeslot": func(f *File) bool { return f.FreeSlot },
+	"locked":   func(f *File) bool { return f.Locked },
+	"vbr": func(f *File) bool {
+		v, found := attribute(peer.VBR)(f)
+		return found && v == 1
+	},
+}
+
+// operators compare a field to a value, longest first so that ie. >= is not taken for >.
+var operators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// ParseFilter parses expr, space separated terms a result has to match all of:
+//
+//	bitrate>=320        compares a field with =, !=, <, <=, > or >=
+//	duration 120..600   an inclusive range of a field, either end may be left out
+//	ext:flac,wav        any of the file extensions
+//	freeslot            a flag, one of freeslot, locked and vbr
+//	path~"(?i)remaster" a regular expression the path matches, quoted if it has spaces
+//	live                a word of the path, case-insensitively
+//	-live               prefixed with a dash, any term is negated
+//
+// The fields are bitrate (kbps), duration (seconds), samplerate (Hz), bitdepth, size
+// (bytes), speed (bytes per second) and queue. Sizes and speeds take the units B, KB,
+// MB and GB, 1024 based, speeds may end in /s. Durations take s, m and h. A result
+// without the attribute a field needs, ie. bitrate, does not match.
+func ParseFilter(expr string) (*Filter, error) {
+	terms, err := terms(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := new(Filter)
+	for i := 0; i < len(terms); i++ {
+		term := terms[i]
+
+		// A field followed by a range, ie. duration 120..600.
+		if _, found := fields[strings.ToLower(term)]; found && i+1 < len(terms) && strings.Contains(terms[i+1], "..") {
+			term += ":" + terms[i+1]
+			i++
+		}
+
+		c, err := parseCondition(term)
+		if err != nil {
+			return nil, err
+		}
+
+		f.conditions = append(f.conditions, c)
+	}
+
+	return f, nil
+}
+
+// Match reports whether r passes every term of the filter.
+func (f *Filter) Match(r *File) bool {
+	if r.File == nil {
+		return false
+	}
+
+	for _, c := range f.conditions {
+		if c.match(r) == c.negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply returns the results that pass the filter, ie. of State.Search. The returned
+// channel is closed once results is or ctx is done, usually that of the search.
+func (f *Filter) Apply(ctx context.Context, results <-chan *File) chan *File {
+	filtered := make(chan *File)
+
+	go func() {
+		defer close(filtered)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case r, ok := <-results:
+				if !ok {
+					return
+				}
+
+				if !f.Match(r) {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+
+				case filtered <- r:
+				}
+			}
+		}
+	}()
+
+	return filtered
+}
+
+// terms splits expr at spaces outside of double quotes.
+func terms(expr string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	var quoted, escaped bool
+
+	for _, r := range expr {
+		switch {
+		case escaped:
+			escaped = false
+
+		case quoted && r == '\\':
+			escaped = true
+
+		case r == '"':
+			quoted = !quoted
+
+		case !quoted && unicode.IsSpace(r):
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+
+			continue
+		}
+
+		term.WriteRune(r)
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote in %q", ErrFilter, expr)
+	}
+
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+
+	return terms, nil
+}
+
+// parseCondition parses a single term of a filter.
+func parseCondition(term string) (condition, error) {
+	var c condition
+	if rest, found := strings.CutPrefix(term, "-"); found {
+		c.negate, term = true, rest
+	}
+
+	i := strings.IndexAny(term, "<>=!:~")
+	if i < 0 {
+		match, err := parseWord(term)
+		c.match = match
+
+		return c, err
+	}
+
+	key, rest := strings.ToLower(term[:i]), term[i:]
+
+	var err error
+	switch {
+	case strings.HasPrefix(rest, "~"):
+		c.match, err = parsePattern(key, unquote(rest[1:]))
+
+	case strings.HasPrefix(rest, ":"):
+		c.match, err = parseList(key, unquote(rest[1:]))
+
+	default:
+		c.match, err = parseComparison(key, rest)
+	}
+
+	if err != nil {
+		return c, fmt.Errorf("%w: %q: %w", ErrFilter, term, err)
+	}
+
+	return c, nil
+}
+
+// parseWord parses a term that is a flag, or else a word of the path.
+func parseWord(term string) (func(*File) bool, error) {
+	if flag, found := flags[strings.ToLower(term)]; found {
+		return flag, nil
+	}
+
+	want := words(unquote(term))
+	if len(want) == 0 {
+		return nil, fmt.Errorf("%w: %q has no words", ErrFilter, term)
+	}
+
+	return func(f *File) bool {
+		have := words(f.Name)
+		for _, w := range want {
+			if !slices.Contains(have, w) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// parsePattern parses the regular expression of a path~ term.
+func parsePattern(key, pattern string) (func(*File) bool, error) {
+	if key != "path" {
+		return nil, fmt.Errorf("unknown pattern field %q", key)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(f *File) bool { return re.MatchString(f.Name) }, nil
+}
+
+// parseList parses the values of an ext: term, or the range of a field.
+func parseList(key, values string) (func(*File) bool, error) {
+	if fd, found := fields[key]; found {
+		return parseRange(fd, values)
+	}
+
+	if key != "ext" {
+		return nil, fmt.Errorf("unknown field %q", key)
+	}
+
+	var exts []string
+	for _, ext := range strings.Split(values, ",") {
+		ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if ext != "" {
+			exts = append(exts, ext)
+		}
+	}
+
+	if len(exts) == 0 {
+		return nil, errors.New("no extensions")
+	}
+
+	return func(f *File) bool {
+		ext := strings.TrimPrefix(path.Ext(virtualBase(f.Name)), ".")
+		return slices.ContainsFunc(exts, func(e string) bool { return strings.EqualFold(e, ext) })
+	}, nil
+}
+
+// parseRange parses the inclusive range lo..hi of fd, either end may be left out.
+func parseRange(fd field, values string) (func(*File) bool, error) {
+	from, to, found := strings.Cut(values, "..")
+	if !found || (from == "" && to == "") {
+		return nil, fmt.Errorf("range %q is not from..to", values)
+	}
+
+	lo, hi := -1.0, -1.0
+	for _, end := range []struct {
+		text  string
+		value *float64
+	}{{from, &lo}, {to, &hi}} {
+		if end.text == "" {
+			continue
+		}
+
+		v, err := amount(end.text, fd.units)
+		if err != nil {
+			return nil, err
+		}
+
+		*end.value = v
+	}
+
+	return func(f *File) bool {
+		v, found := fd.value(f)
+		return found && (lo < 0 || v >= lo) && (hi < 0 || v <= hi)
+	}, nil
+}
+
+// parseComparison parses a field compared to a value, rest starts with the operator.
+func parseComparison(key, rest string) (func(*File) bool, error) {
+	fd, found := fields[key]
+	if !found {
+		return nil, fmt.Errorf("unknown field %q", key)
+	}
+
+	i := slices.IndexFunc(operators, func(op string) bool { return strings.HasPrefix(rest, op) })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown operator in %q", rest)
+	}
+
+	op := operators[i]
+
+	want, err := amount(rest[len(op):], fd.units)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(f *File) bool {
+		v, found := fd.value(f)
+		if !found {
+			return false
+		}
+
+		switch op {
+		case ">=":
+			return v >= want
+		case "<=":
+			return v <= want
+		case "!=":
+			return v != want
+		case ">":
+			return v > want
+		case "<":
+			return v < want
+		default:
+			return v == want
+		}
+	}, nil
+}
+
+// amount parses a number followed by one of units, ie. 200MB.
+func amount(s string, units map[string]float64) (float64, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "/s")
+
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("number %q: %w", s, err)
+	}
+
+	if i == len(s) {
+		return n, nil
+	}
+
+	unit, found := units[s[i:]]
+	if !found {
+		return 0, fmt.Errorf("unknown unit %q", s[i:])
+	}
+
+	return n * unit, nil
+}
+
+// unquote strips the double quotes around s, if any, and the backslashes escaping quotes
+// in it.
+func unquote(s string) string {
+	if len(s) < 2 || !strings.HasPrefix(s, `"`) || !strings.HasSuffix(s, `"`) {
+		return s
+	}
+
+	return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+}
+
+// attribute returns the value of the file attribute with code.
+func attribute(code peer.FileAttributeType) func(*File) (float64, bool) {
+	return func(f *File) (float64, bool) {
+		for _, a := range f.Attributes {
+			if a.Code == code {
+				return float64(a.Value), true
+			}
+		}
+
+		return 0, false
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Parallel()
+
+	flac := &File{
+		Username:     "user1",
+		FreeSlot:     true,
+		AverageSpeed: 2 << 20,
+		File: &peer.File{
+			Name: `@@music\Artist\Album (Remastered)\01 Track.flac`,
+			Size: 30 << 20,
+			Attributes: []peer.Attribute{
+				{Code: peer.Duration, Value: 240},
+				{Code: peer.SampleRate, Value: 44100},
+				{Code: peer.BitDepth, Value: 16},
+			},
+		},
+	}
+
+	mp3 := &File{
+		Username:     "user2",
+		Queue:        12,
+		AverageSpeed: 512 << 10,
+		Locked:       true,
+		File: &peer.File{
+			Name: `@@music\Artist\Live at the Hall\01 Track.mp3`,
+			Size: 8 << 20,
+			Attributes: []peer.Attribute{
+				{Code: peer.Bitrate, Value: 320},
+				{Code: peer.Duration, Value: 700},
+				{Code: peer.VBR, Value: 1},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		expr  string
+		match []*File
+	}{
+		"empty":              {``, []*File{flac, mp3}},
+		"bitrate":            {`bitrate>=320`, []*File{mp3}},
+		"no bitrate":         {`-bitrate>=320`, []*File{flac}},
+		"extensions":         {`ext:flac,wav`, []*File{flac}},
+		"dotted extension":   {`ext:.MP3`, []*File{mp3}},
+		"size":               {`size<20MB`, []*File{mp3}},
+		"duration range":     {`duration 120..600`, []*File{flac}},
+		"duration open":      {`duration:10m..`, []*File{mp3}},
+		"free slot":          {`freeslot`, []*File{flac}},
+		"locked":             {`-locked`, []*File{flac}},
+		"vbr":                {`vbr`, []*File{mp3}},
+		"speed":              {`speed>1MB/s`, []*File{flac}},
+		"queue":              {`queue<10`, []*File{flac}},
+		"exclude word":       {`-live`, []*File{flac}},
+		"word":               {`hall`, []*File{mp3}},
+		"quoted words":       {`"live at"`, []*File{mp3}},
+		"pattern":            {`path~"(?i)remaster"`, []*File{flac}},
+		"pattern with space": {`path~"Live at"`, []*File{mp3}},
+		"sample rate":        {`samplerate=44100 bitdepth!=24`, []*File{flac}},
+		"all terms":          {`ext:flac,mp3 freeslot size>1mb`, []*File{flac}},
+		"nothing":            {`ext:flac -freeslot`, nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := ParseFilter(tt.expr)
+			require.NoError(t, err)
+
+			var match []*File
+			for _, r := range []*File{flac, mp3} {
+				if f.Match(r) {
+					match = append(match, r)
+				}
+			}
+
+			assert.Equal(t, tt.match, match)
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		`bitrate>=fast`,
+		`size<200XB`,
+		`colour=red`,
+		`bitrate=>320`,
+		`ext:`,
+		`duration ..`,
+		`name~x`,
+		`path~"(unclosed"`,
+		`path~"unterminated`,
+		`-`,
+	} {
+		_, err := ParseFilter(expr)
+		assert.ErrorIs(t, err, ErrFilter, expr)
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	t.Parallel()
+
+	f, err := ParseFilter(`ext:flac`)
+	require.NoError(t, err)
+
+	results := make(chan *File, 3)
+	results <- &File{File: &peer.File{Name: `a.flac`}}
+	results <- &File{File: &peer.File{Name: `b.mp3`}}
+	results <- &File{}
+	close(results)
+
+	var names []string
+	for r := range f.Apply(context.Background(), results) {
+		names = append(names, r.Name)
+	}
+
+	assert.Equal(t, []string{"a.flac"}, names)
+
+	// results is never closed, the filter stops with ctx.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	results = make(chan *File, 1)
+	results <- &File{File: &peer.File{Name: `a.flac`}}
+
+	filtered := f.Apply(ctx, results)
+	cancel()
+
+	select {
+	case <-time.After(time.Second):
+		assert.Fail(t, "filter still running")
+
+	case <-drained(filtered):
+	}
+}
+
+// drained is closed once files is, reading it to the end.
+func drained(files <-chan *File) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range files {
+		}
+
+		close(done)
+	}()
+
+	return done
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+)
+
+// folderTimeout bounds waiting for a peer to list a folder, see FolderContents.
+const folderTimeout = time.Minute
+
+// FolderContents asks username for the files in remoteFolder and, if recursive, in its
+// subfolders. Peers that list only remoteFolder itself are asked for their whole shared
+// file list, which has the subfolders. The files are ready for Download or
+// DownloadManager.Add and keep the folder's structure once downloaded: with LayoutFolder,
+// @@music\Artist\Album\CD1\track.flac of the folder @@music\Artist\Album is saved as
+// Album/CD1/track.flac.
+func (s *State) FolderContents(ctx context.Context, username, remoteFolder string, recursive bool) ([]*File, error) {
+	p, err := s.open(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// The listener must be ready before the peer can answer.
+	responses := p.Relays.FolderContentsResponse.Listener(1)
+	defer responses.Close()
+
+	conn, obfuscated := p.Conn(peer.ConnectionType)
+	if conn == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoConnection, username)
+	}
+
+	token := soul.NewToken()
+	_, err = peer.Write(conn, &peer.FolderContentsRequest{Token: token, Folder: remoteFolder}, obfuscated)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, folderTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case r := <-responses.Ch():
+			if r.Token != token {
+				continue
+			}
+
+			directories := r.Folders
+			if recursive && !subfolders(remoteFolder, directories) {
+				shared, err := s.sharedDirectories(ctx, p)
+				if err != nil {
+					s.log.Debug().Err(err).Str("username", username).Str("folder", remoteFolder).Msg("shared file list")
+				} else {
+					directories = shared
+				}
+			}
+
+			return folderFiles(username, remoteFolder, directories, recursive), nil
+		}
+	}
+}
+
+// sharedDirectories asks p for its shared file list and returns all of its directories,
+// the ones shared with us alone included.
+func (s *State) sharedDirectories(ctx context.Context, p *Peer) ([]peer.Directory, error) {
+	// The listener must be ready before the peer can answer.
+	responses := p.Relays.SharedFileListResponse.Listener(1)
+	defer closeListener(responses)
+
+	conn, obfuscated := p.Conn(peer.ConnectionType)
+	if conn == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoConnection, p.username)
+	}
+
+	_, err := peer.Write(conn, &peer.SharedFileListRequest{}, obfuscated)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case r := <-responses.Ch():
+		return append(r.Directories, r.PrivateDirectories...), nil
+	}
+}
+
+// ErrNoDownloads is returned by DownloadFolder before SetDownloads.
+var ErrNoDownloads = errors.New("no download manager")
+
+// SetDownloads sets the DownloadManager that DownloadFolder queues files with.
+func (s *State) SetDownloads(m *DownloadManager) {
+	s.mu.Lock()
+	s.downloads = m
+	s.mu.Unlock()
+}
+
+// DownloadFolder queues the files in remoteFolder and its subfolders with the
+// DownloadManager set by SetDownloads, which tracks and limits them like any other
+// download, and returns them. Use FolderContents and DownloadManager.Add to leave the
+// subfolders out.
+func (s *State) DownloadFolder(ctx context.Context, username, remoteFolder string) ([]*File, error) {
+	s.mu.RLock()
+	m := s.downloads
+	s.mu.RUnlock()
+
+	if m == nil {
+		return nil, ErrNoDownloads
+	}
+
+	files, err := s.FolderContents(ctx, username, remoteFolder, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		err = m.Add(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// subfolders reports whether directories has any subfolder of folder.
+func subfolders(folder string, directories []peer.Directory) bool {
+	folder = strings.TrimRight(folder, `\/`)
+
+	return slices.ContainsFunc(directories, func(d peer.Directory) bool {
+		return strings.HasPrefix(d.Name, folder+virtualSeparator)
+	})
+}
+
+// folderFiles returns the files of the directories a peer listed for folder, the ones in
+// subfolders too if recursive. Directory listings name files without their directory.
+func folderFiles(username, folder string, directories []peer.Directory, recursive bool) []*File {
+	folder = strings.TrimRight(folder, `\/`)
+
+	var files []*File
+	for _, d := range directories {
+		name := strings.TrimRight(d.Name, `\/`)
+		if name != folder && (!recursive || !strings.HasPrefix(name, folder+virtualSeparator)) {
+			continue
+		}
+
+		for _, f := range d.Files {
+			f.Name = name + virtualSeparator + virtualBase(f.Name)
+
+			files = append(files, &File{
+				Username: username,
+				Token:    soul.NewToken(),
+				Folder:   folder,
+				File:     &f,
+			})
+		}
+	}
+
+	return files
+}
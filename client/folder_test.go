@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadFolder(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shared := t.TempDir()
+	for name, content := range map[string]string{
+		"Album/a.mp3":     "track a",
+		"Album/CD1/b.mp3": "track b",
+		"Other/c.mp3":     "track c",
+	} {
+		path := filepath.Join(shared, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	login(ctx, t, s, "user2", shared)
+
+	album := `@@` + filepath.Base(shared) + `\Album`
+
+	files, err := state1.FolderContents(ctx, "user2", album, false)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, album+`\a.mp3`, files[0].Name)
+
+	_, err = state1.DownloadFolder(ctx, "user2", album)
+	assert.ErrorIs(t, err, ErrNoDownloads)
+
+	m, err := NewDownloadManager(state1)
+	require.NoError(t, err)
+
+	state1.SetDownloads(m)
+	go m.Run(ctx)
+
+	files, err = state1.DownloadFolder(ctx, "user2", album)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	require.Eventually(t, func() bool {
+		downloads := m.Downloads()
+		for _, d := range downloads {
+			if d.State != TransferCompleted {
+				return false
+			}
+		}
+
+		return len(downloads) == 2
+	}, 10*time.Second, 10*time.Millisecond)
+
+	for name, content := range map[string]string{
+		"Album/a.mp3":     "track a",
+		"Album/CD1/b.mp3": "track b",
+	} {
+		downloaded, err := os.ReadFile(filepath.Join(state1.client.config.DownloadFolder, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(downloaded))
+	}
+}
+
+func TestFolderContentsSharedList(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := login(ctx, t, s, "user1", "")
+
+	// user2 lists only the folder asked for, its subfolder is in its shared file list.
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	p := NewPeer(state.client.config, &peer.PeerInit{Username: "user2", ConnectionType: peer.ConnectionType})
+	state.mu.Lock()
+	state.peers["user2"] = p
+	state.mu.Unlock()
+
+	state.initializers(ctx, peer.ConnectionType, p, local, false, state.log)
+
+	album := `@@music\Album`
+	mp3 := func(name string) []peer.File {
+		return []peer.File{{Name: name, Size: 1, Extension: "mp3"}}
+	}
+
+	go func() {
+		for {
+			r, _, code, err := peer.Read(peer.Code(0), remote, false)
+			if err != nil {
+				return
+			}
+
+			switch code {
+			case peer.CodeFolderContentsRequest:
+				m := new(peer.FolderContentsRequest)
+				if !assert.NoError(t, m.Deserialize(r)) {
+					return
+				}
+
+				_, err = peer.Write(remote, &peer.FolderContentsResponse{
+					Token:   m.Token,
+					Folder:  m.Folder,
+					Folders: []peer.Directory{{Name: album, Files: mp3("a.mp3")}},
+				}, false)
+				assert.NoError(t, err)
+
+			case peer.CodeSharedFileListRequest:
+				_, err = peer.Write(remote, &peer.SharedFileListResponse{
+					Directories: []peer.Directory{
+						{Name: album, Files: mp3("a.mp3")},
+						{Name: `@@music\Other`, Files: mp3("c.mp3")},
+					},
+					PrivateDirectories: []peer.Directory{
+						{Name: album + `\CD1`, Files: mp3("b.mp3")},
+					},
+				}, false)
+				assert.NoError(t, err)
+			}
+		}
+	}()
+
+	files, err := state.FolderContents(ctx, "user2", album, true)
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+
+	assert.Equal(t, []string{album + `\a.mp3`, album + `\CD1\b.mp3`}, names)
+}
+
+func TestFolderFiles(t *testing.T) {
+	t.Parallel()
+
+	directories := []peer.Directory{
+		{Name: `@@music\Album`, Files: []peer.File{{Name: "a.mp3"}}},
+		{Name: `@@music\Album\CD1`, Files: []peer.File{{Name: "b.mp3"}}},
+		{Name: `@@music\Album 2`, Files: []peer.File{{Name: "c.mp3"}}},
+	}
+
+	names := func(files []*File) []string {
+		var names []string
+		for _, f := range files {
+			assert.Equal(t, "user", f.Username)
+			assert.Equal(t, `@@music\Album`, f.Folder)
+			names = append(names, f.Name)
+		}
+
+		return names
+	}
+
+	assert.Equal(t, []string{`@@music\Album\a.mp3`}, names(folderFiles("user", `@@music\Album\`, directories, false)))
+	assert.Equal(t, []string{`@@music\Album\a.mp3`, `@@music\Album\CD1\b.mp3`}, names(folderFiles("user", `@@music\Album`, directories, true)))
+}
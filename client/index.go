@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/server"
+	"github.com/charlievieth/fastwalk"
+	"github.com/rs/zerolog"
+)
+
+// indexCache is the name of the share index cache file in Config.StateFolder.
+const indexCache = "index.gob"
+
+// indexEntry is a shared file together with what we last saw of it on disk.
+type indexEntry struct {
+	ModTime int64
+	Size    int64
+	File    peer.File
+}
+
+// shareIndex is the share index as saved in the cache file.
+type shareIndex struct {
+	Shares map[string]string
+	Files  map[string]indexEntry
+}
+
+// index walks the shared folders and returns their files keyed by local path. Files found
+// in cached with the same modification time and size are reused as they are, the rest are
+// stat-ed and parsed anew, see readMetadata. Walking stops early if ctx is done.
+func index(ctx context.Context, shares map[string]string, cached map[string]indexEntry, log zerolog.Logger) (map[string]indexEntry, error) {
+	files := make(map[string]indexEntry, len(cached))
+	var mu sync.Mutex
+	for name, folder := range shares {
+		walkFn := func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("index")
+				return nil // returning the error stops iteration
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			i, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			e, found := cached[path]
+			if !found || e.ModTime != i.ModTime().UnixNano() || e.Size != i.Size() {
+				virtual, err := virtualPath(name, folder, path)
+				if err != nil {
+					return err
+				}
+
+				e = indexEntry{
+					ModTime: i.ModTime().UnixNano(),
+					Size:    i.Size(),
+					File:    indexFile(path, virtual, i, log),
+				}
+			}
+
+			mu.Lock()
+			files[path] = e
+			mu.Unlock()
+
+			return nil
+		}
+
+		err := fastwalk.Walk(&fastwalk.DefaultConfig, folder, walkFn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// directories groups indexed files by the virtual directory they are in. As in every
+// directory listing, files are named without their directory.
+func directories(files map[string]indexEntry) []peer.Directory {
+	shared := make(map[string][]peer.File, 0)
+	for _, e := range files {
+		f := e.File
+		f.Name = virtualBase(f.Name)
+
+		shared[virtualDir(e.File.Name)] = append(shared[virtualDir(e.File.Name)], f)
+	}
+
+	directories := make([]peer.Directory, 0, len(shared))
+	for k, v := range shared {
+		directories = append(directories, peer.Directory{
+			Name:  k,
+			Files: v,
+		})
+	}
+
+	return directories
+}
+
+// indexFile returns the shared file entry for path, advertised as virtual. Files we can
+// not read metadata from are still shared, without attributes.
+func indexFile(path, virtual string, info fs.FileInfo, log zerolog.Logger) peer.File {
+	f := peer.File{
+		Name:       virtual,
+		Size:       uint64(info.Size()),
+		Extension:  filepath.Ext(info.Name()),
+		Attributes: []peer.Attribute{},
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("index open")
+		return f
+	}
+
+	defer file.Close()
+
+	m, err := readMetadata(file, info.Size())
+	if err != nil {
+		if !errors.Is(err, ErrUnknownFormat) {
+			log.Debug().Err(err).Str("path", path).Msg("index metadata")
+		}
+
+		return f
+	}
+
+	f.Attributes = m.attributes()
+
+	return f
+}
+
+// loadIndex reads the cached index of shares from folder. A missing cache, or one of
+// other shares, returns no files and the shares are indexed from scratch.
+func loadIndex(folder string, shares map[string]string) (map[string]indexEntry, error) {
+	var cache shareIndex
+	found, err := load(folder, indexCache, &cache)
+	if !found || err != nil {
+		return nil, err
+	}
+
+	if !maps.Equal(cache.Shares, shares) {
+		return nil, nil
+	}
+
+	// Gob does not tell empty slices from nil ones.
+	for path, e := range cache.Files {
+		if e.File.Attributes == nil {
+			e.File.Attributes = []peer.Attribute{}
+			cache.Files[path] = e
+		}
+	}
+
+	return cache.Files, nil
+}
+
+// saveIndex writes the index of shares to folder.
+func saveIndex(folder string, shares map[string]string, files map[string]indexEntry) error {
+	return save(folder, indexCache, &shareIndex{Shares: shares, Files: files})
+}
+
+// load decodes the file name in the state folder into v. It reports whether the file
+// exists, nothing is read if folder is empty.
+func load(folder, name string, v any) (bool, error) {
+	if folder == "" {
+		return false, nil
+	}
+
+	f, err := os.Open(filepath.Join(folder, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+
+	return true, gob.NewDecoder(f).Decode(v)
+}
+
+// save encodes v to the file name in the state folder. It writes to a temporary file
+// first so that a crash never leaves a truncated file behind. Nothing is written if
+// folder is empty.
+func save(folder, name string, v any) error {
+	if folder == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(folder, 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(folder, name+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	err = gob.NewEncoder(f).Encode(v)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(folder, name))
+}
+
+// Rescan indexes the shared folders again, re-parsing only new or modified files, and saves
+// the index to Config.StateFolder. Once logged in, the server is told the new number
+// of shared folders and files.
+func (s *State) Rescan(ctx context.Context) error {
+	s.rescan.Lock()
+	defer s.rescan.Unlock()
+
+	s.mu.RLock()
+	cached := s.indexed
+	s.mu.RUnlock()
+
+	files, err := index(ctx, s.shares, cached, s.log)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.indexed = files
+	s.shared = &peer.SharedFileListResponse{Directories: directories(files)}
+	s.virtuals = virtuals(files)
+	s.searchIndex = newSearchIndex(files)
+	online := s.online
+	s.mu.Unlock()
+
+	err = saveIndex(s.client.config.StateFolder, s.shares, files)
+	if err != nil {
+		return err
+	}
+
+	if !online {
+		return nil
+	}
+
+	_, err = server.Write(s.client.Conn(), s.sharedFoldersFiles())
+
+	return err
+}
+
+// sharedFoldersFiles returns the number of shared folders and files we report to the server.
+func (s *State) sharedFoldersFiles() *server.SharedFoldersFiles {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := &server.SharedFoldersFiles{
+		Directories: s.client.config.SharedFolders,
+		Files:       s.client.config.SharedFiles,
+	}
+
+	if m.Directories == 0 {
+		m.Directories = len(s.shared.Directories)
+	}
+
+	if m.Files == 0 {
+		m.Files = len(s.indexed)
+	}
+
+	return m
+}
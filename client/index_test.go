@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	dir := library(t)
+
+	err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not audio"), 0644)
+	require.NoError(t, err)
+
+	files, err := index(context.Background(), map[string]string{"music": dir}, nil, zerolog.Nop())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	mp3 := files[filepath.Join(dir, "file_example_MP3_700KB.mp3")]
+	assert.Equal(t, `@@music\file_example_MP3_700KB.mp3`, mp3.File.Name)
+	assert.Equal(t, ".mp3", mp3.File.Extension)
+	assert.Equal(t, []peer.Attribute{
+		{Code: peer.Bitrate, Value: 140},
+		{Code: peer.Duration, Value: 42},
+		{Code: peer.VBR, Value: 1},
+	}, mp3.File.Attributes)
+
+	notes := files[filepath.Join(dir, "notes.txt")]
+	assert.Equal(t, int64(9), notes.Size)
+	assert.Empty(t, notes.File.Attributes)
+
+	directories := directories(files)
+	require.Len(t, directories, 1)
+	assert.Equal(t, "@@music", directories[0].Name)
+	assert.ElementsMatch(t, []string{"file_example_MP3_700KB.mp3", "notes.txt"},
+		[]string{directories[0].Files[0].Name, directories[0].Files[1].Name})
+}
+
+func TestIndexCache(t *testing.T) {
+	t.Parallel()
+
+	dir := library(t)
+	shares := map[string]string{"music": dir}
+	state := t.TempDir()
+
+	notes := filepath.Join(dir, "notes.txt")
+	err := os.WriteFile(notes, []byte("not audio"), 0644)
+	require.NoError(t, err)
+
+	files, err := index(context.Background(), shares, nil, zerolog.Nop())
+	require.NoError(t, err)
+
+	// Unchanged files must come from the cache, so mark the cached entry.
+	mp3 := filepath.Join(dir, "file_example_MP3_700KB.mp3")
+	e := files[mp3]
+	e.File.Attributes = []peer.Attribute{{Code: peer.Bitrate, Value: 1}}
+	files[mp3] = e
+
+	err = saveIndex(state, shares, files)
+	require.NoError(t, err)
+
+	cached, err := loadIndex(state, shares)
+	require.NoError(t, err)
+	assert.Equal(t, files, cached)
+
+	other, err := loadIndex(state, map[string]string{"music": t.TempDir()})
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	// Modify one file, remove the other and add a new one.
+	err = os.Chtimes(mp3, time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	err = os.Remove(notes)
+	require.NoError(t, err)
+
+	added := filepath.Join(dir, "added.txt")
+	err = os.WriteFile(added, []byte("added"), 0644)
+	require.NoError(t, err)
+
+	files, err = index(context.Background(), shares, cached, zerolog.Nop())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Len(t, files[mp3].File.Attributes, 3)
+	assert.Contains(t, files, added)
+	assert.NotContains(t, files, notes)
+
+	// Nothing changed since, so everything is reused.
+	e = files[mp3]
+	e.File.Attributes = nil
+	files[mp3] = e
+
+	again, err := index(context.Background(), shares, files, zerolog.Nop())
+	require.NoError(t, err)
+	assert.Equal(t, files, again)
+}
+
+func TestIndexContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := index(ctx, map[string]string{"music": library(t)}, nil, zerolog.Nop())
+	assert.ErrorIs(t, err, context.Canceled)
+}
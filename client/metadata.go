@@ -0,0 +1,795 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/bh90210/soul/peer"
+)
+
+// ErrUnknownFormat is returned when a file is not an audio format we can read metadata from.
+var ErrUnknownFormat = errors.New("unknown audio format")
+
+// maxHeaderRead is the most we read from a file looking for audio headers.
+const maxHeaderRead = 64 * 1024
+
+// metadata holds the audio properties we advertise as file attributes.
+type metadata struct {
+	// bitrate in kbps.
+	bitrate uint32
+	// duration in seconds.
+	duration   uint32
+	vbr        bool
+	sampleRate uint32
+	bitDepth   uint32
+	// lossless formats advertise sample rate and bit depth instead of bitrate.
+	lossless bool
+}
+
+// attributes returns the file attributes in the order other clients expect them:
+// duration, sample rate and bit depth for lossless files, bitrate, duration and VBR otherwise.
+func (m *metadata) attributes() []peer.Attribute {
+	attributes := []peer.Attribute{}
+
+	if m.lossless {
+		if m.duration > 0 {
+			attributes = append(attributes, peer.Attribute{Code: peer.Duration, Value: m.duration})
+		}
+
+		if m.sampleRate > 0 {
+			attributes = append(attributes, peer.Attribute{Code: peer.SampleRate, Value: m.sampleRate})
+		}
+
+		if m.bitDepth > 0 {
+			attributes = append(attributes, peer.Attribute{Code: peer.BitDepth, Value: m.bitDepth})
+		}
+
+		return attributes
+	}
+
+	if m.bitrate > 0 {
+		attributes = append(attributes, peer.Attribute{Code: peer.Bitrate, Value: m.bitrate})
+	}
+
+	if m.duration > 0 {
+		attributes = append(attributes, peer.Attribute{Code: peer.Duration, Value: m.duration})
+	}
+
+	var vbr uint32
+	if m.vbr {
+		vbr = 1
+	}
+
+	attributes = append(attributes, peer.Attribute{Code: peer.VBR, Value: vbr})
+
+	return attributes
+}
+
+// readMetadata detects the audio format of r by its magic bytes and parses its headers.
+// It supports MP3, FLAC, Ogg (Vorbis, Opus, FLAC) and MP4 (AAC, ALAC). Any other
+// format returns ErrUnknownFormat.
+func readMetadata(r io.ReaderAt, size int64) (*metadata, error) {
+	start, err := skipID3(r)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, 8)
+	_, err = r.ReadAt(magic, start)
+	if err != nil {
+		return nil, errors.Join(ErrUnknownFormat, err)
+	}
+
+	switch {
+	case bytes.Equal(magic[:4], []byte("fLaC")):
+		return readFLAC(r, start)
+
+	case bytes.Equal(magic[:4], []byte("OggS")):
+		return readOgg(r, start, size)
+
+	case bytes.Equal(magic[4:8], []byte("ftyp")):
+		return readMP4(r, start, size)
+
+	case magic[0] == 0xff && magic[1]&0xe0 == 0xe0, start > 0:
+		return readMP3(r, start, size)
+
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// skipID3 returns the offset after the ID3v2 tag at the start of r, zero if there is none.
+func skipID3(r io.ReaderAt) (int64, error) {
+	header := make([]byte, 10)
+	_, err := r.ReadAt(header, 0)
+	if err != nil {
+		return 0, errors.Join(ErrUnknownFormat, err)
+	}
+
+	if !bytes.Equal(header[:3], []byte("ID3")) {
+		return 0, nil
+	}
+
+	// Tag size is a 28 bit synchsafe integer and excludes the header and footer.
+	size := int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f)
+
+	size += 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+
+	return size, nil
+}
+
+// mp3Bitrates are the bitrates in kbps indexed by [version 1 or 2][layer 1 to 3][bitrate index].
+var mp3Bitrates = [2][3][16]uint32{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+// mp3SampleRates are the sample rates in Hz indexed by [MPEG 1, 2, 2.5][sample rate index].
+var mp3SampleRates = [3][3]uint32{
+	{44100, 48000, 32000},
+	{22050, 24000, 16000},
+	{11025, 12000, 8000},
+}
+
+// mp3Frame is a decoded MPEG audio frame header.
+type mp3Frame struct {
+	// mpeg is 0 for MPEG 1, 1 for MPEG 2 and 2 for MPEG 2.5.
+	mpeg       int
+	layer      int
+	bitrate    uint32
+	sampleRate uint32
+	samples    uint32
+	mono       bool
+	length     int
+}
+
+// parseMP3Frame decodes a four byte MPEG audio frame header. It returns false if h is not one.
+func parseMP3Frame(h []byte) (*mp3Frame, bool) {
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return nil, false
+	}
+
+	f := &mp3Frame{}
+
+	switch (h[1] >> 3) & 0x03 {
+	case 0:
+		f.mpeg = 2
+	case 2:
+		f.mpeg = 1
+	case 3:
+		f.mpeg = 0
+	default:
+		return nil, false
+	}
+
+	switch (h[1] >> 1) & 0x03 {
+	case 1:
+		f.layer = 3
+	case 2:
+		f.layer = 2
+	case 3:
+		f.layer = 1
+	default:
+		return nil, false
+	}
+
+	bitrateIndex, sampleRateIndex := h[2]>>4, (h[2]>>2)&0x03
+	if bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return nil, false
+	}
+
+	version := min(f.mpeg, 1)
+	f.bitrate = mp3Bitrates[version][f.layer-1][bitrateIndex]
+	f.sampleRate = mp3SampleRates[f.mpeg][sampleRateIndex]
+	f.mono = h[3]>>6 == 3
+
+	padding := int(h[2]>>1) & 0x01
+
+	switch {
+	case f.layer == 1:
+		f.samples = 384
+		f.length = (int(12*f.bitrate*1000/f.sampleRate) + padding) * 4
+
+	case f.layer == 3 && f.mpeg != 0:
+		f.samples = 576
+		f.length = int(72*f.bitrate*1000/f.sampleRate) + padding
+
+	default:
+		f.samples = 1152
+		f.length = int(144*f.bitrate*1000/f.sampleRate) + padding
+	}
+
+	return f, true
+}
+
+// readMP3 finds the first MPEG audio frame at or after start and reads the Xing, Info or
+// VBRI header in it if present. Without one the file is taken to be constant bitrate.
+func readMP3(r io.ReaderAt, start, size int64) (*metadata, error) {
+	buf := make([]byte, maxHeaderRead)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	buf = buf[:n]
+
+	// Look for a frame header followed by another one, so that stray sync bytes
+	// in leftover tag data are not mistaken for audio.
+	offset := -1
+	var frame *mp3Frame
+	for i := 0; i+4 <= len(buf); i++ {
+		f, ok := parseMP3Frame(buf[i : i+4])
+		if !ok {
+			continue
+		}
+
+		next := i + f.length
+		if next+4 <= len(buf) {
+			if _, ok := parseMP3Frame(buf[next : next+4]); !ok {
+				continue
+			}
+		}
+
+		offset, frame = i, f
+		break
+	}
+
+	if frame == nil {
+		return nil, ErrUnknownFormat
+	}
+
+	m := &metadata{
+		sampleRate: frame.sampleRate,
+		bitrate:    frame.bitrate,
+	}
+
+	audio := size - start - int64(offset)
+
+	// An ID3v1 tag at the end of the file is not audio.
+	tag := make([]byte, 3)
+	if size >= 128 {
+		_, err = r.ReadAt(tag, size-128)
+		if err == nil && bytes.Equal(tag, []byte("TAG")) {
+			audio -= 128
+		}
+	}
+
+	// The Xing/Info header sits right after the side information.
+	side := 17
+	switch {
+	case frame.mpeg == 0 && !frame.mono:
+		side = 32
+	case frame.mpeg != 0 && frame.mono:
+		side = 9
+	}
+
+	var frames, bytesCount uint32
+	xing := buf[offset:]
+	switch {
+	case len(xing) >= 4+side+16 && (bytes.Equal(xing[4+side:8+side], []byte("Xing")) || bytes.Equal(xing[4+side:8+side], []byte("Info"))):
+		// LAME writes Info for constant bitrate files and Xing for variable ones.
+		m.vbr = bytes.Equal(xing[4+side:8+side], []byte("Xing"))
+
+		flags := binary.BigEndian.Uint32(xing[8+side:])
+		field := 12 + side
+		if flags&0x01 != 0 {
+			frames = binary.BigEndian.Uint32(xing[field:])
+			field += 4
+		}
+
+		if flags&0x02 != 0 {
+			bytesCount = binary.BigEndian.Uint32(xing[field:])
+		}
+
+	case len(xing) >= 36+18 && bytes.Equal(xing[36:40], []byte("VBRI")):
+		m.vbr = true
+		bytesCount = binary.BigEndian.Uint32(xing[46:])
+		frames = binary.BigEndian.Uint32(xing[50:])
+	}
+
+	if bytesCount > 0 {
+		audio = int64(bytesCount)
+	}
+
+	var seconds float64
+	if frames > 0 {
+		seconds = float64(frames) * float64(frame.samples) / float64(frame.sampleRate)
+	} else if frame.bitrate > 0 {
+		seconds = float64(audio) * 8 / float64(frame.bitrate*1000)
+	}
+
+	m.duration = uint32(math.Round(seconds))
+
+	if frames > 0 && seconds > 0 {
+		m.bitrate = uint32(math.Round(float64(audio) * 8 / seconds / 1000))
+	}
+
+	return m, nil
+}
+
+// readFLAC reads the STREAMINFO block that follows the fLaC marker at start.
+func readFLAC(r io.ReaderAt, start int64) (*metadata, error) {
+	// Marker, metadata block header and the 34 byte STREAMINFO.
+	buf := make([]byte, 4+4+34)
+	_, err := r.ReadAt(buf, start)
+	if err != nil {
+		return nil, errors.Join(ErrUnknownFormat, err)
+	}
+
+	// STREAMINFO must be the first metadata block.
+	if buf[4]&0x7f != 0 {
+		return nil, ErrUnknownFormat
+	}
+
+	return parseStreamInfo(buf[8:]), nil
+}
+
+// parseStreamInfo decodes a FLAC STREAMINFO block.
+func parseStreamInfo(info []byte) *metadata {
+	// Sample rate 20 bits, channels 3 bits, bits per sample 5 bits, total samples 36 bits.
+	packed := binary.BigEndian.Uint64(info[10:18])
+
+	sampleRate := uint32(packed >> 44)
+	bitDepth := uint32((packed>>36)&0x1f) + 1
+	samples := packed & 0xfffffffff
+
+	m := &metadata{
+		sampleRate: sampleRate,
+		bitDepth:   bitDepth,
+		lossless:   true,
+	}
+
+	if sampleRate > 0 {
+		m.duration = uint32(math.Round(float64(samples) / float64(sampleRate)))
+	}
+
+	return m
+}
+
+// readOgg reads the identification header in the first Ogg page at start and the
+// granule position of the last page of the same stream for the duration.
+func readOgg(r io.ReaderAt, start, size int64) (*metadata, error) {
+	buf := make([]byte, 27+255)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if n < 27 {
+		return nil, ErrUnknownFormat
+	}
+
+	serial := binary.LittleEndian.Uint32(buf[14:18])
+	segments := int(buf[26])
+	if n < 27+segments {
+		return nil, ErrUnknownFormat
+	}
+
+	var length int
+	for _, s := range buf[27 : 27+segments] {
+		length += int(s)
+	}
+
+	packet := make([]byte, length)
+	_, err = r.ReadAt(packet, start+27+int64(segments))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var m *metadata
+	// Granule positions count samples at this rate, less the pre-skip.
+	var rate uint32
+	var preSkip uint64
+
+	switch {
+	case length >= 30 && bytes.Equal(packet[:7], []byte("\x01vorbis")):
+		m = &metadata{
+			sampleRate: binary.LittleEndian.Uint32(packet[12:16]),
+			vbr:        true,
+		}
+
+		maximum := int32(binary.LittleEndian.Uint32(packet[16:20]))
+		nominal := int32(binary.LittleEndian.Uint32(packet[20:24]))
+		minimum := int32(binary.LittleEndian.Uint32(packet[24:28]))
+
+		if nominal > 0 {
+			m.bitrate = uint32(nominal / 1000)
+			m.vbr = maximum != nominal || minimum != nominal
+		}
+
+		rate = m.sampleRate
+
+	case length >= 19 && bytes.Equal(packet[:8], []byte("OpusHead")):
+		// Opus always decodes at 48kHz, the header carries the input rate for information only.
+		m = &metadata{
+			sampleRate: binary.LittleEndian.Uint32(packet[12:16]),
+			vbr:        true,
+		}
+
+		if m.sampleRate == 0 {
+			m.sampleRate = 48000
+		}
+
+		rate = 48000
+		preSkip = uint64(binary.LittleEndian.Uint16(packet[10:12]))
+
+	case length >= 13+38 && bytes.Equal(packet[:5], []byte("\x7fFLAC")) && bytes.Equal(packet[9:13], []byte("fLaC")):
+		m = parseStreamInfo(packet[17:])
+		rate = m.sampleRate
+
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	granule, err := lastGranule(r, size, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	if rate > 0 && granule > preSkip {
+		seconds := float64(granule-preSkip) / float64(rate)
+		m.duration = uint32(math.Round(seconds))
+
+		if m.bitrate == 0 && !m.lossless && seconds > 0 {
+			m.bitrate = uint32(math.Round(float64(size-start) * 8 / seconds / 1000))
+		}
+	}
+
+	return m, nil
+}
+
+// lastGranule returns the granule position of the last Ogg page of the given stream.
+func lastGranule(r io.ReaderAt, size int64, serial uint32) (uint64, error) {
+	offset := max(size-maxHeaderRead, 0)
+
+	buf := make([]byte, size-offset)
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+
+	buf = buf[:n]
+
+	for i := bytes.LastIndex(buf, []byte("OggS")); i >= 0; i = bytes.LastIndex(buf[:i], []byte("OggS")) {
+		if i+27 > len(buf) || binary.LittleEndian.Uint32(buf[i+14:i+18]) != serial {
+			continue
+		}
+
+		granule := binary.LittleEndian.Uint64(buf[i+6 : i+14])
+		// Pages where no packet finishes carry -1.
+		if granule == math.MaxUint64 {
+			continue
+		}
+
+		return granule, nil
+	}
+
+	return 0, nil
+}
+
+// mp4Track is what we need from an MP4 trak atom.
+type mp4Track struct {
+	handler   string
+	timescale uint32
+	duration  uint64
+	format    string
+	metadata  metadata
+}
+
+// readMP4 walks the moov atom for the first sound track and reads its media header
+// and sample description.
+func readMP4(r io.ReaderAt, start, size int64) (*metadata, error) {
+	var track *mp4Track
+	err := mp4Boxes(r, start, size, func(kind string, from, to int64) error {
+		if kind != "moov" {
+			return nil
+		}
+
+		return mp4Boxes(r, from, to, func(kind string, from, to int64) error {
+			if kind != "trak" || track != nil {
+				return nil
+			}
+
+			t, err := readMP4Track(r, from, to)
+			if err != nil {
+				return err
+			}
+
+			if t.handler == "soun" {
+				track = t
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if track == nil {
+		return nil, ErrUnknownFormat
+	}
+
+	m := track.metadata
+
+	var seconds float64
+	if track.timescale > 0 {
+		seconds = float64(track.duration) / float64(track.timescale)
+		m.duration = uint32(math.Round(seconds))
+	}
+
+	if m.bitrate == 0 && !m.lossless && seconds > 0 {
+		m.bitrate = uint32(math.Round(float64(size-start) * 8 / seconds / 1000))
+	}
+
+	return &m, nil
+}
+
+// readMP4Track reads the handler, media header and sample description of a trak atom.
+func readMP4Track(r io.ReaderAt, from, to int64) (*mp4Track, error) {
+	t := &mp4Track{}
+
+	return t, mp4Boxes(r, from, to, func(kind string, from, to int64) error {
+		if kind != "mdia" {
+			return nil
+		}
+
+		return mp4Boxes(r, from, to, func(kind string, from, to int64) error {
+			switch kind {
+			case "hdlr":
+				b, err := mp4Read(r, from, to)
+				if err != nil {
+					return err
+				}
+
+				if len(b) >= 12 {
+					t.handler = string(b[8:12])
+				}
+
+			case "mdhd":
+				b, err := mp4Read(r, from, to)
+				if err != nil {
+					return err
+				}
+
+				switch {
+				case len(b) >= 32 && b[0] == 1:
+					t.timescale = binary.BigEndian.Uint32(b[20:24])
+					t.duration = binary.BigEndian.Uint64(b[24:32])
+
+				case len(b) >= 20:
+					t.timescale = binary.BigEndian.Uint32(b[12:16])
+					t.duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+				}
+
+			case "minf":
+				return mp4Boxes(r, from, to, func(kind string, from, to int64) error {
+					if kind != "stbl" {
+						return nil
+					}
+
+					return mp4Boxes(r, from, to, func(kind string, from, to int64) error {
+						if kind != "stsd" {
+							return nil
+						}
+
+						b, err := mp4Read(r, from, to)
+						if err != nil {
+							return err
+						}
+
+						parseMP4SampleDescription(b, t)
+
+						return nil
+					})
+				})
+			}
+
+			return nil
+		})
+	})
+}
+
+// parseMP4SampleDescription reads the first audio sample entry of an stsd atom.
+func parseMP4SampleDescription(b []byte, t *mp4Track) {
+	// Version, flags and entry count precede the first entry.
+	if len(b) < 8+36 {
+		return
+	}
+
+	entry := b[8:]
+	entrySize := int(binary.BigEndian.Uint32(entry[:4]))
+	if entrySize > len(entry) || entrySize < 36 {
+		entrySize = len(entry)
+	}
+
+	entry = entry[:entrySize]
+	t.format = string(entry[4:8])
+
+	// Size, format, reserved and data reference index take 16 bytes, then version,
+	// revision, vendor, channels, sample size, compression id, packet size and a
+	// 16.16 sample rate.
+	t.metadata.bitDepth = uint32(binary.BigEndian.Uint16(entry[26:28]))
+	t.metadata.sampleRate = binary.BigEndian.Uint32(entry[32:36]) >> 16
+
+	children := entry[36:]
+	for len(children) >= 8 {
+		size := int(binary.BigEndian.Uint32(children[:4]))
+		if size < 8 || size > len(children) {
+			break
+		}
+
+		kind, body := string(children[4:8]), children[8:size]
+		switch {
+		case kind == "esds" && t.format == "mp4a":
+			parseESDS(body, &t.metadata)
+
+		case kind == "alac" && len(body) >= 4+24:
+			// Version and flags, then the ALACSpecificConfig.
+			config := body[4:]
+			t.metadata.bitDepth = uint32(config[5])
+			t.metadata.sampleRate = binary.BigEndian.Uint32(config[20:24])
+
+		case kind == "dfLa" && len(body) >= 4+4+34:
+			// Version and flags, then FLAC metadata blocks starting with STREAMINFO.
+			info := parseStreamInfo(body[8:])
+			t.metadata.sampleRate, t.metadata.bitDepth = info.sampleRate, info.bitDepth
+		}
+
+		children = children[size:]
+	}
+
+	switch t.format {
+	case "alac", "fLaC":
+		t.metadata.lossless = true
+
+	default:
+		t.metadata.bitDepth = 0
+	}
+}
+
+// parseESDS reads the average and maximum bitrate from the decoder config descriptor
+// of an esds atom.
+func parseESDS(b []byte, m *metadata) {
+	// Version and flags.
+	if len(b) < 4 {
+		return
+	}
+
+	b = b[4:]
+
+	descriptor := func(b []byte) (byte, []byte, bool) {
+		if len(b) < 2 {
+			return 0, nil, false
+		}
+
+		tag := b[0]
+		var length int
+		i := 1
+		for ; i < 5 && i < len(b); i++ {
+			length = length<<7 | int(b[i]&0x7f)
+			if b[i]&0x80 == 0 {
+				break
+			}
+		}
+
+		i++
+		if i+length > len(b) {
+			length = len(b) - i
+		}
+
+		if length < 0 {
+			return 0, nil, false
+		}
+
+		return tag, b[i : i+length], true
+	}
+
+	tag, es, ok := descriptor(b)
+	if !ok || tag != 0x03 || len(es) < 3 {
+		return
+	}
+
+	flags := es[2]
+	es = es[3:]
+
+	if flags&0x80 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+
+	if flags&0x40 != 0 && len(es) >= 1 {
+		es = es[min(1+int(es[0]), len(es)):]
+	}
+
+	if flags&0x20 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+
+	tag, config, ok := descriptor(es)
+	if !ok || tag != 0x04 || len(config) < 13 {
+		return
+	}
+
+	maximum := binary.BigEndian.Uint32(config[5:9])
+	average := binary.BigEndian.Uint32(config[9:13])
+
+	if average > 0 {
+		m.bitrate = uint32(math.Round(float64(average) / 1000))
+	}
+
+	m.vbr = maximum != average
+}
+
+// maxMP4Box is the largest leaf atom we read into memory.
+const maxMP4Box = 1 << 20
+
+// mp4Boxes calls fn with the type and body range of every atom between from and to.
+func mp4Boxes(r io.ReaderAt, from, to int64, fn func(kind string, from, to int64) error) error {
+	header := make([]byte, 16)
+	for from+8 <= to {
+		_, err := r.ReadAt(header[:8], from)
+		if err != nil {
+			return errors.Join(ErrUnknownFormat, err)
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:8])
+		body := from + 8
+
+		switch size {
+		case 0:
+			size = to - from
+
+		case 1:
+			_, err = r.ReadAt(header[8:16], from+8)
+			if err != nil {
+				return errors.Join(ErrUnknownFormat, err)
+			}
+
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			body += 8
+		}
+
+		if size < body-from || from+size > to {
+			return ErrUnknownFormat
+		}
+
+		err = fn(kind, body, from+size)
+		if err != nil {
+			return err
+		}
+
+		from += size
+	}
+
+	return nil
+}
+
+// mp4Read reads the body of a leaf atom.
+func mp4Read(r io.ReaderAt, from, to int64) ([]byte, error) {
+	if to-from > maxMP4Box {
+		return nil, ErrUnknownFormat
+	}
+
+	b := make([]byte, to-from)
+	_, err := r.ReadAt(b, from)
+	if err != nil {
+		return nil, errors.Join(ErrUnknownFormat, err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	mp3, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		file     []byte
+		metadata *metadata
+	}{
+		"mp3 xing": {
+			file:     mp3,
+			metadata: &metadata{bitrate: 140, duration: 42, vbr: true, sampleRate: 44100},
+		},
+		"mp3 cbr": {
+			file:     cbrMP3(100),
+			metadata: &metadata{bitrate: 128, duration: 3, sampleRate: 44100},
+		},
+		"flac": {
+			file:     flac(44100, 16, 44100*180),
+			metadata: &metadata{duration: 180, sampleRate: 44100, bitDepth: 16, lossless: true},
+		},
+		"id3 flac": {
+			file:     append(id3(100), flac(96000, 24, 96000*60)...),
+			metadata: &metadata{duration: 60, sampleRate: 96000, bitDepth: 24, lossless: true},
+		},
+		"ogg vorbis": {
+			file:     ogg(vorbisHeader(44100, 192000), 44100*30),
+			metadata: &metadata{bitrate: 192, duration: 30, vbr: true, sampleRate: 44100},
+		},
+		"ogg opus": {
+			file:     ogg(opusHead(312, 44100), 48000*60+312),
+			metadata: &metadata{duration: 60, vbr: true, sampleRate: 44100},
+		},
+		"m4a aac": {
+			file:     mp4(44100, 44100*200, mp4a(44100, 256000, 256000)),
+			metadata: &metadata{bitrate: 256, duration: 200, sampleRate: 44100},
+		},
+		"m4a alac": {
+			file:     mp4(96000, 96000*240, alac(96000, 24)),
+			metadata: &metadata{duration: 240, sampleRate: 96000, bitDepth: 24, lossless: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := readMetadata(bytes.NewReader(test.file), int64(len(test.file)))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.metadata, m)
+		})
+	}
+}
+
+func TestMetadataUnknown(t *testing.T) {
+	t.Parallel()
+
+	file := []byte("this is not an audio file")
+	_, err := readMetadata(bytes.NewReader(file), int64(len(file)))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestMetadataAttributes(t *testing.T) {
+	t.Parallel()
+
+	lossy := &metadata{bitrate: 320, duration: 200, vbr: true, sampleRate: 44100}
+	assert.Equal(t, []peer.Attribute{
+		{Code: peer.Bitrate, Value: 320},
+		{Code: peer.Duration, Value: 200},
+		{Code: peer.VBR, Value: 1},
+	}, lossy.attributes())
+
+	lossless := &metadata{bitrate: 900, duration: 200, sampleRate: 44100, bitDepth: 16, lossless: true}
+	assert.Equal(t, []peer.Attribute{
+		{Code: peer.Duration, Value: 200},
+		{Code: peer.SampleRate, Value: 44100},
+		{Code: peer.BitDepth, Value: 16},
+	}, lossless.attributes())
+}
+
+// cbrMP3 returns frames MPEG 1 layer III frames at 128kbps, 44.1kHz, without a Xing header.
+func cbrMP3(frames int) []byte {
+	var b []byte
+	for range frames {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x64})
+		b = append(b, frame...)
+	}
+
+	return b
+}
+
+// id3 returns an empty ID3v2 tag of the given size.
+func id3(size int) []byte {
+	b := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, byte(size >> 7), byte(size & 0x7f)}
+	return append(b, make([]byte, size)...)
+}
+
+// streamInfo returns a FLAC STREAMINFO block.
+func streamInfo(sampleRate, bitDepth uint32, samples uint64) []byte {
+	b := make([]byte, 34)
+	binary.BigEndian.PutUint16(b[0:], 4096)
+	binary.BigEndian.PutUint16(b[2:], 4096)
+	binary.BigEndian.PutUint64(b[10:], uint64(sampleRate)<<44|1<<41|uint64(bitDepth-1)<<36|samples)
+
+	return b
+}
+
+// flac returns a FLAC file with only the STREAMINFO block.
+func flac(sampleRate, bitDepth uint32, samples uint64) []byte {
+	b := []byte("fLaC")
+	b = append(b, 0x80, 0, 0, 34)
+
+	return append(b, streamInfo(sampleRate, bitDepth, samples)...)
+}
+
+// ogg returns an Ogg stream of two pages: the identification header and a last page
+// with the given granule position.
+func ogg(header []byte, granule uint64) []byte {
+	page := func(kind byte, granule uint64, sequence uint32, packet []byte) []byte {
+		b := []byte("OggS")
+		b = append(b, 0, kind)
+		b = binary.LittleEndian.AppendUint64(b, granule)
+		b = binary.LittleEndian.AppendUint32(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, sequence)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = append(b, 1, byte(len(packet)))
+
+		return append(b, packet...)
+	}
+
+	b := page(0x02, 0, 0, header)
+	return append(b, page(0x04, granule, 1, make([]byte, 100))...)
+}
+
+// vorbisHeader returns a Vorbis identification header with only a nominal bitrate.
+func vorbisHeader(sampleRate, nominal uint32) []byte {
+	b := []byte("\x01vorbis")
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, 2)
+	b = binary.LittleEndian.AppendUint32(b, sampleRate)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, nominal)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+
+	return append(b, 0xb8, 1)
+}
+
+// opusHead returns an Opus identification header.
+func opusHead(preSkip uint16, sampleRate uint32) []byte {
+	b := []byte("OpusHead")
+	b = append(b, 1, 2)
+	b = binary.LittleEndian.AppendUint16(b, preSkip)
+	b = binary.LittleEndian.AppendUint32(b, sampleRate)
+
+	return append(b, 0, 0, 0)
+}
+
+// box returns an MP4 atom.
+func box(kind string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	return append(binary.BigEndian.AppendUint32([]byte{}, uint32(8+len(b))), append([]byte(kind), b...)...)
+}
+
+// sampleEntry returns an MP4 audio sample entry.
+func sampleEntry(format string, sampleRate uint32, bitDepth uint16, children ...[]byte) []byte {
+	b := make([]byte, 28)
+	binary.BigEndian.PutUint16(b[6:], 1)
+	binary.BigEndian.PutUint16(b[16:], 2)
+	binary.BigEndian.PutUint16(b[18:], bitDepth)
+	binary.BigEndian.PutUint32(b[24:], sampleRate<<16)
+
+	return box(format, append(b, bytes.Join(children, nil)...))
+}
+
+// mp4a returns an AAC sample entry with an esds atom.
+func mp4a(sampleRate, average, maximum uint32) []byte {
+	config := []byte{0x40, 0x15, 0, 0, 0}
+	config = binary.BigEndian.AppendUint32(config, maximum)
+	config = binary.BigEndian.AppendUint32(config, average)
+
+	es := []byte{0, 1, 0, 0x04, byte(len(config))}
+	es = append(es, config...)
+
+	esds := []byte{0, 0, 0, 0, 0x03, 0x80, 0x80, 0x80, byte(len(es))}
+	esds = append(esds, es...)
+
+	return sampleEntry("mp4a", sampleRate, 16, box("esds", esds))
+}
+
+// alac returns an ALAC sample entry with its specific config.
+func alac(sampleRate uint32, bitDepth byte) []byte {
+	config := make([]byte, 4+24)
+	binary.BigEndian.PutUint32(config[4:], 4096)
+	config[9] = bitDepth
+	config[13] = 2
+	binary.BigEndian.PutUint32(config[24:], sampleRate)
+
+	return sampleEntry("alac", sampleRate, uint16(bitDepth), box("alac", config))
+}
+
+// mp4 returns an MP4 file with a single sound track described by entry.
+func mp4(timescale, duration uint32, entry []byte) []byte {
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], timescale)
+	binary.BigEndian.PutUint32(mdhd[16:], duration)
+
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:], "soun")
+
+	stsd := binary.BigEndian.AppendUint32(make([]byte, 4), 1)
+	stsd = append(stsd, entry...)
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("M4A "), make([]byte, 4)),
+		box("moov",
+			box("trak",
+				box("mdia",
+					box("mdhd", mdhd),
+					box("hdlr", hdlr),
+					box("minf", box("stbl", box("stsd", stsd))),
+				),
+			),
+		),
+		box("mdat", make([]byte, 64)),
+	}, nil)
+}
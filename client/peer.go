@@ -101,6 +101,7 @@ func (p *Peer) New(connType soul.ConnectionType, conn net.Conn, obfuscated bool)
 
 		p.connD = conn
 		p.ctxD, p.cancelD = context.WithCancel(context.Background())
+		ctx = p.ctxD
 		p.mu.Unlock()
 
 		go p.readD(p.ctxD)
@@ -158,6 +159,32 @@ func (p *Peer) Conn(connType soul.ConnectionType, token ...soul.Token) (net.Conn
 	}
 }
 
+// connected reports whether the P connection is open.
+func (p *Peer) connected() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.conn != nil && p.ctx.Err() == nil
+}
+
+// address returns the address the peer listens on, a nil ip if we do not know it.
+func (p *Peer) address() (net.IP, int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.ip, p.port
+}
+
+// setAddress records the address the peer listens on.
+func (p *Peer) setAddress(ip net.IP, port, obfuscatedPort int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ip = ip
+	p.port = port
+	p.obfuscatedPort = obfuscatedPort
+}
+
 func (p *Peer) read(ctx context.Context, conn net.Conn, obfuscated bool, wg *sync.WaitGroup) {
 	wg.Wait()
 
@@ -468,3 +495,14 @@ func (p *Peer) relayInit() {
 	p.Relays.Distributed.EmbeddedMessage = broadcast.NewRelay[*distributed.EmbeddedMessage]()
 	p.Relays.Distributed.Search = broadcast.NewRelay[*distributed.Search]()
 }
+
+// closeListener closes l without holding up its relay. Notifications sent to l while it
+// closes are dropped, so that the relay's other listeners still get theirs.
+func closeListener[T any](l *broadcast.Listener[T]) {
+	go func() {
+		for range l.Ch() {
+		}
+	}()
+
+	l.Close()
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bh90210/soul/peer"
+)
+
+const (
+	// rankSpeed is the upload speed, in bytes per second, that scores half of the best.
+	rankSpeed = 1 << 20
+	// rankQueue is the queue length that scores half of an empty queue.
+	rankQueue = 10
+	// rankBitrate is the bitrate of lossy files that scores best, in kbps.
+	rankBitrate = 320
+)
+
+// lossless are the extensions of lossless audio files.
+var lossless = []string{"flac", "wav", "aiff", "aif", "ape", "wv", "alac"}
+
+// Ranking weighs what makes a search result a good pick, see SearchSession.Best. Each
+// of them scores a result from 0 to 1, and the weighted sum ranks it.
+type Ranking struct {
+	// Quality scores lossless files best, 24 bit over 16 bit, and lossy files by their
+	// bitrate. Lossy files without a bitrate attribute score nothing.
+	Quality float64
+	// FreeSlot scores users with a free upload slot.
+	FreeSlot float64
+	// Speed scores users by the upload speed they advertise.
+	Speed float64
+	// Queue scores users by how few uploads they have queued.
+	Queue float64
+	// Name scores how close the file name is to the query.
+	Name float64
+}
+
+// Best returns the n best results collected so far, best first, all of them if n is
+// zero. Locked results are left out, see File.Locked.
+func (ss *SearchSession) Best(n int) []*File {
+	query := queryWords(ss.Query)
+
+	var files []*File
+	for _, u := range ss.Snapshot() {
+		for _, folder := range u.Folders {
+			files = append(files, unlocked(folder.Files)...)
+		}
+	}
+
+	scores := make(map[*File]float64, len(files))
+	for _, f := range files {
+		scores[f] = ss.Ranking.score(query, f)
+	}
+
+	slices.SortStableFunc(files, func(a, b *File) int {
+		return cmp.Compare(scores[b], scores[a])
+	})
+
+	if n > 0 && n < len(files) {
+		files = files[:n]
+	}
+
+	return files
+}
+
+// BestFolder returns the folder whose results score best, so that a whole album comes
+// from one user, nil if there are no results. A folder scores the average of its results
+// times how many results it has next to the folder with the most, so that a single track
+// does not beat the whole album. Folders with more results win a tie. Locked results are
+// left out, see File.Locked.
+func (ss *SearchSession) BestFolder() *SearchFolder {
+	query := queryWords(ss.Query)
+
+	var folders []*SearchFolder
+	var most int
+	for _, u := range ss.Snapshot() {
+		for _, folder := range u.Folders {
+			files := unlocked(folder.Files)
+			if len(files) == 0 {
+				continue
+			}
+
+			folders = append(folders, &SearchFolder{Name: folder.Name, Files: files})
+			most = max(most, len(files))
+		}
+	}
+
+	var best *SearchFolder
+	var bestScore float64
+	for _, folder := range folders {
+		var score float64
+		for _, f := range folder.Files {
+			score += ss.Ranking.score(query, f)
+		}
+
+		// The average times len(folder.Files) / most.
+		score /= float64(most)
+
+		if best == nil || score > bestScore || (score == bestScore && len(folder.Files) > len(best.Files)) {
+			best, bestScore = folder, score
+		}
+	}
+
+	return best
+}
+
+// score returns the weighted score of f as a result of the search for the query words.
+func (r Ranking) score(query []string, f *File) float64 {
+	var freeSlot float64
+	if f.FreeSlot {
+		freeSlot = 1
+	}
+
+	return r.Quality*quality(f) +
+		r.FreeSlot*freeSlot +
+		r.Speed*float64(f.AverageSpeed)/float64(f.AverageSpeed+rankSpeed) +
+		r.Queue*rankQueue/float64(f.Queue+rankQueue) +
+		r.Name*closeness(query, f.Name)
+}
+
+// quality scores the audio quality of f from 0 to 1.
+func quality(f *File) float64 {
+	depth, hasDepth := attribute(peer.BitDepth)(f)
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(virtualBase(f.Name)), "."))
+
+	if hasDepth || slices.Contains(lossless, ext) {
+		if depth >= 24 {
+			return 1
+		}
+
+		return 0.9
+	}
+
+	bitrate, found := attribute(peer.Bitrate)(f)
+	if !found {
+		return 0
+	}
+
+	// Lossy files score below lossless ones.
+	return 0.8 * min(bitrate, rankBitrate) / rankBitrate
+}
+
+// closeness scores from 0 to 1 how close the file name is to the query words: half for
+// how many of them the file name has, a word only found in its folders counting half,
+// and half for how few other words the file name has.
+func closeness(query []string, name string) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+
+	// The words of the file name without its extension, each once.
+	base := virtualBase(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	ws := words(base)
+	slices.Sort(ws)
+	ws = slices.Compact(ws)
+
+	all := words(name)
+
+	var found, shared float64
+	for _, w := range query {
+		switch {
+		case slices.Contains(ws, w):
+			found++
+			shared++
+
+		case slices.Contains(all, w):
+			found += 0.5
+		}
+	}
+
+	// The words of the query and the file name together, ie. their union.
+	union := float64(len(query))
+	for _, w := range ws {
+		if !slices.Contains(query, w) {
+			union++
+		}
+	}
+
+	return (found/float64(len(query)) + shared/union) / 2
+}
+
+// queryWords returns the distinct words of query a result should have, leaving out the
+// excluded ones.
+func queryWords(query string) []string {
+	var ws []string
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "-") {
+			continue
+		}
+
+		for _, w := range words(term) {
+			if !slices.Contains(ws, w) {
+				ws = append(ws, w)
+			}
+		}
+	}
+
+	return ws
+}
+
+// unlocked returns the files that are not locked.
+func unlocked(files []*File) []*File {
+	var open []*File
+	for _, f := range files {
+		if !f.Locked {
+			open = append(open, f)
+		}
+	}
+
+	return open
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBest(t *testing.T) {
+	t.Parallel()
+
+	session := newSearchSession("artist track", soul.NewToken(), 0)
+	session.Ranking = DefaultConfig().Ranking
+
+	assert.Empty(t, session.Best(1))
+
+	session.add(&peer.FileSearchResponse{
+		Username:     "slow",
+		Queue:        300,
+		AverageSpeed: 10 << 10,
+		Results:      []peer.File{{Name: `@@music\Artist\Track.mp3`, Attributes: []peer.Attribute{{Code: peer.Bitrate, Value: 128}}}},
+	})
+	session.add(&peer.FileSearchResponse{
+		Username:     "fast",
+		FreeSlot:     true,
+		AverageSpeed: 5 << 20,
+		Results:      []peer.File{{Name: `@@music\Artist\Track.flac`}},
+	})
+	session.add(&peer.FileSearchResponse{
+		Username:       "locked",
+		FreeSlot:       true,
+		AverageSpeed:   10 << 20,
+		PrivateResults: []peer.File{{Name: `@@music\Artist\Track.flac`}},
+	})
+	session.add(&peer.FileSearchResponse{
+		Username:     "other",
+		FreeSlot:     true,
+		AverageSpeed: 5 << 20,
+		Results:      []peer.File{{Name: `@@music\Artist\Another Song.flac`}},
+	})
+
+	best := session.Best(1)
+	require.Len(t, best, 1)
+	assert.Equal(t, "fast", best[0].Username)
+
+	var users []string
+	for _, f := range session.Best(0) {
+		users = append(users, f.Username)
+	}
+
+	assert.Equal(t, []string{"fast", "other", "slow"}, users)
+
+	// With only the name counting, the order the results arrived in breaks the tie.
+	session.Ranking = Ranking{Name: 1}
+	assert.Equal(t, "slow", session.Best(1)[0].Username)
+}
+
+func TestBestFolder(t *testing.T) {
+	t.Parallel()
+
+	session := newSearchSession("artist album", soul.NewToken(), 0)
+	session.Ranking = DefaultConfig().Ranking
+
+	assert.Nil(t, session.BestFolder())
+
+	album := func(folder string, n int) []peer.File {
+		var files []peer.File
+		for i := range n {
+			files = append(files, peer.File{Name: folder + `\` + string(rune('a'+i)) + `.flac`})
+		}
+
+		return files
+	}
+
+	// A single track from a fast user, a whole album from a queued one and from one with
+	// a free slot.
+	session.add(&peer.FileSearchResponse{Username: "single", FreeSlot: true, AverageSpeed: 5 << 20, Results: album(`@@music\Artist - Album`, 1)})
+	session.add(&peer.FileSearchResponse{Username: "queued", Queue: 50, Results: album(`@@music\Artist - Album`, 10)})
+	session.add(&peer.FileSearchResponse{Username: "free", FreeSlot: true, AverageSpeed: 5 << 20, Results: album(`@@share\Artist\Album`, 10)})
+
+	folder := session.BestFolder()
+	require.NotNil(t, folder)
+	assert.Equal(t, `@@share\Artist\Album`, folder.Name)
+	assert.Len(t, folder.Files, 10)
+
+	for _, f := range folder.Files {
+		assert.Equal(t, "free", f.Username)
+	}
+}
+
+func TestBestFolderComplete(t *testing.T) {
+	t.Parallel()
+
+	session := newSearchSession("artist album", soul.NewToken(), 0)
+	session.Ranking = DefaultConfig().Ranking
+
+	// A single 24 bit track from a fast user with a free slot scores best on its own.
+	session.add(&peer.FileSearchResponse{
+		Username:     "single",
+		FreeSlot:     true,
+		AverageSpeed: 5 << 20,
+		Results: []peer.File{{
+			Name:       `@@music\Artist - Album Artist Album.flac`,
+			Attributes: []peer.Attribute{{Code: peer.BitDepth, Value: 24}},
+		}},
+	})
+
+	var album []peer.File
+	for i := range 12 {
+		album = append(album, peer.File{
+			Name:       `@@share\Artist\Album\` + string(rune('a'+i)) + `.mp3`,
+			Attributes: []peer.Attribute{{Code: peer.Bitrate, Value: 320}},
+		})
+	}
+
+	session.add(&peer.FileSearchResponse{Username: "album", Queue: 5, AverageSpeed: 1 << 20, Results: album})
+
+	folder := session.BestFolder()
+	require.NotNil(t, folder)
+	assert.Equal(t, `@@share\Artist\Album`, folder.Name)
+	assert.Len(t, folder.Files, 12)
+}
+
+func TestRankingScores(t *testing.T) {
+	t.Parallel()
+
+	file := func(name string, attributes ...peer.Attribute) *File {
+		return &File{File: &peer.File{Name: name, Attributes: attributes}}
+	}
+
+	assert.Equal(t, 1.0, quality(file(`a.wav`, peer.Attribute{Code: peer.BitDepth, Value: 24})))
+	assert.Equal(t, 0.9, quality(file(`a.flac`)))
+	assert.Equal(t, 0.8, quality(file(`a.mp3`, peer.Attribute{Code: peer.Bitrate, Value: 320})))
+	assert.Equal(t, 0.4, quality(file(`a.mp3`, peer.Attribute{Code: peer.Bitrate, Value: 160})))
+	assert.Zero(t, quality(file(`a.mp3`)))
+
+	query := queryWords("Artist track -live")
+	assert.Equal(t, []string{"artist", "track"}, query)
+
+	assert.Equal(t, 1.0, closeness(query, `@@music\Artist Track.mp3`))
+	assert.Equal(t, 0.625, closeness(query, `@@music\Artist\Track.mp3`))
+	assert.Less(t, closeness(query, `@@music\Artist\Track (extended mix).mp3`), 0.625)
+	assert.Zero(t, closeness(query, `@@music\Other.mp3`))
+	assert.Zero(t, closeness(nil, `@@music\Other.mp3`))
+}
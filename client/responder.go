@@ -0,0 +1,308 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/bh90210/soul/peer"
+)
+
+// respondTimeout bounds answering a single search, connecting to the peer included.
+const respondTimeout = time.Minute
+
+// searchIndex is an inverted index of the words in shared files' virtual paths. It
+// answers searches the way other SoulSeek clients do: every term must be a word of
+// the path, -term excludes paths with that word and *term matches words ending with
+// term. Matching is case-insensitive.
+type searchIndex struct {
+	files []peer.File
+	// words maps every word to the indexes of the files it appears in, in ascending order.
+	words map[string][]int
+	// reversed are the words spelled backwards, sorted, so that the words ending with a
+	// suffix are a range of them.
+	reversed []string
+}
+
+// newSearchIndex indexes the words of files.
+func newSearchIndex(files map[string]indexEntry) *searchIndex {
+	i := &searchIndex{
+		files: make([]peer.File, 0, len(files)),
+		words: make(map[string][]int),
+	}
+
+	for _, e := range files {
+		i.files = append(i.files, e.File)
+	}
+
+	// Stable results for the same index.
+	slices.SortFunc(i.files, func(a, b peer.File) int { return strings.Compare(a.Name, b.Name) })
+
+	for n, f := range i.files {
+		for _, word := range words(f.Name) {
+			postings := i.words[word]
+			// A word repeated in a path is indexed once.
+			if len(postings) > 0 && postings[len(postings)-1] == n {
+				continue
+			}
+
+			i.words[word] = append(postings, n)
+		}
+	}
+
+	i.reversed = make([]string, 0, len(i.words))
+	for word := range i.words {
+		i.reversed = append(i.reversed, reverse(word))
+	}
+
+	slices.Sort(i.reversed)
+
+	return i
+}
+
+// reverse returns s spelled backwards.
+func reverse(s string) string {
+	r := []rune(s)
+	slices.Reverse(r)
+
+	return string(r)
+}
+
+// suffixed returns the words ending with suffix.
+func (i *searchIndex) suffixed(suffix string) []string {
+	prefix := reverse(suffix)
+
+	var ws []string
+	n, _ := slices.BinarySearch(i.reversed, prefix)
+	for ; n < len(i.reversed) && strings.HasPrefix(i.reversed[n], prefix); n++ {
+		ws = append(ws, reverse(i.reversed[n]))
+	}
+
+	return ws
+}
+
+// words splits s into lower case words.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// search returns up to limit files matching query, all of them if limit is zero. A query
+// without any term to include matches nothing.
+func (i *searchIndex) search(query string, limit int) []peer.File {
+	var include, exclude, suffixes []string
+	for _, term := range strings.Fields(query) {
+		switch {
+		case strings.HasPrefix(term, "-"):
+			exclude = append(exclude, words(term)...)
+
+		case strings.HasPrefix(term, "*"):
+			w := words(term)
+			if len(w) == 0 {
+				continue
+			}
+
+			suffixes = append(suffixes, w[0])
+			include = append(include, w[1:]...)
+
+		default:
+			include = append(include, words(term)...)
+		}
+	}
+
+	if len(include)+len(suffixes) == 0 {
+		return nil
+	}
+
+	var sets [][]int
+	for _, word := range include {
+		postings, found := i.words[word]
+		if !found {
+			return nil
+		}
+
+		sets = append(sets, postings)
+	}
+
+	for _, suffix := range suffixes {
+		var postings []int
+		for _, word := range i.suffixed(suffix) {
+			postings = append(postings, i.words[word]...)
+		}
+
+		if len(postings) == 0 {
+			return nil
+		}
+
+		slices.Sort(postings)
+		sets = append(sets, slices.Compact(postings))
+	}
+
+	// Intersect starting from the rarest word.
+	slices.SortFunc(sets, func(a, b []int) int { return len(a) - len(b) })
+
+	matches := sets[0]
+	for _, set := range sets[1:] {
+		matches = intersect(matches, set)
+	}
+
+	excluded := make(map[int]struct{})
+	for _, word := range exclude {
+		for _, n := range i.words[word] {
+			excluded[n] = struct{}{}
+		}
+	}
+
+	var results []peer.File
+	for _, n := range matches {
+		if limit > 0 && len(results) == limit {
+			break
+		}
+
+		if _, found := excluded[n]; found {
+			continue
+		}
+
+		results = append(results, i.files[n])
+	}
+
+	return results
+}
+
+// intersect returns the elements of both a and b, which must be in ascending order.
+func intersect(a, b []int) []int {
+	var result []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+
+	return result
+}
+
+// responder answers incoming searches from the share index if Config.AutoRespond is set.
+func (s *State) responder(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case r := <-s.responses:
+			go s.answer(ctx, r)
+		}
+	}
+}
+
+// answer responds to a search with the matching shared files, if any.
+func (s *State) answer(ctx context.Context, r *Search) {
+	if r.Username == s.client.config.Username {
+		return
+	}
+
+	s.mu.RLock()
+	results := s.searchIndex.search(r.Query, s.client.config.MaxSearchResults)
+	s.mu.RUnlock()
+
+	if len(results) == 0 {
+		return
+	}
+
+	s.log.Debug().Str("username", r.Username).Str("query", r.Query).Int("results", len(results)).Msg("answer search")
+
+	files := make([]*File, 0, len(results))
+	for _, f := range results {
+		files = append(files, &File{
+			Username: r.Username,
+			Token:    r.Token,
+			File:     &f,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, respondTimeout)
+	defer cancel()
+
+	err := s.Respond(ctx, files)
+	if err != nil {
+		s.log.Debug().Err(err).Str("username", r.Username).Msg("answer search")
+	}
+}
+
+// uploaded records a finished upload of n bytes that took d, see averageSpeed.
+func (s *State) uploaded(n int64, d time.Duration) {
+	atomic.AddInt64(&s.uploadedBytes, n)
+	atomic.AddInt64(&s.uploadedTime, int64(d))
+}
+
+// averageSpeed returns our average upload speed in bytes per second.
+func (s *State) averageSpeed() int {
+	t := atomic.LoadInt64(&s.uploadedTime)
+	if t == 0 {
+		return 0
+	}
+
+	return int(float64(atomic.LoadInt64(&s.uploadedBytes)) / time.Duration(t).Seconds())
+}
+
+// queueLength returns the number of queued uploads.
+func (s *State) queueLength(ctx context.Context) (int, error) {
+	reply := make(chan int, 1)
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+
+	case s.queueSizeRequest <- reply:
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+
+	case size := <-reply:
+		return size, nil
+	}
+}
+
+// ExcludedResults returns the number of search results dropped so far because they
+// contained a phrase the server excluded.
+func (s *State) ExcludedResults() int64 {
+	return atomic.LoadInt64(&s.excludedResults)
+}
+
+// excluded reports whether name contains any of the lower case phrases.
+func excluded(phrases []string, name string) bool {
+	if len(phrases) == 0 {
+		return false
+	}
+
+	name = strings.ToLower(name)
+	for _, phrase := range phrases {
+		if phrase != "" && strings.Contains(name, phrase) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lower returns phrases in lower case.
+func lower(phrases []string) []string {
+	lower := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		lower = append(lower, strings.ToLower(phrase))
+	}
+
+	return lower
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchIndex(t *testing.T) {
+	t.Parallel()
+
+	files := make(map[string]indexEntry)
+	for _, name := range []string{
+		`@@music\Daft Punk\Discovery\01 - One More Time.flac`,
+		`@@music\Daft Punk\Discovery\02 - Aerodynamic.flac`,
+		`@@music\Daft Punk\Homework\03 - Da Funk.mp3`,
+		`@@music\The Beatles\Abbey Road\01 - Come Together.mp3`,
+		`@@music\The Beatles\Let It Be\06 - Let It Be.mp3`,
+	} {
+		files[name] = indexEntry{File: peer.File{Name: name}}
+	}
+
+	i := newSearchIndex(files)
+
+	tests := map[string]struct {
+		query string
+		limit int
+		names []string
+	}{
+		"all terms": {
+			query: "daft punk flac",
+			names: []string{
+				`@@music\Daft Punk\Discovery\01 - One More Time.flac`,
+				`@@music\Daft Punk\Discovery\02 - Aerodynamic.flac`,
+			},
+		},
+		"case insensitive": {
+			query: "DAFT funk",
+			names: []string{`@@music\Daft Punk\Homework\03 - Da Funk.mp3`},
+		},
+		"path": {
+			query: "discovery aerodynamic",
+			names: []string{`@@music\Daft Punk\Discovery\02 - Aerodynamic.flac`},
+		},
+		"missing term": {
+			query: "daft punk beatles",
+		},
+		"exclude": {
+			query: "beatles -together",
+			names: []string{`@@music\The Beatles\Let It Be\06 - Let It Be.mp3`},
+		},
+		"suffix": {
+			query: "*eatles mp3",
+			names: []string{
+				`@@music\The Beatles\Abbey Road\01 - Come Together.mp3`,
+				`@@music\The Beatles\Let It Be\06 - Let It Be.mp3`,
+			},
+		},
+		"suffix only": {
+			query: "*dynamic",
+			names: []string{`@@music\Daft Punk\Discovery\02 - Aerodynamic.flac`},
+		},
+		"suffix of several words": {
+			query: "*unk mp3",
+			names: []string{`@@music\Daft Punk\Homework\03 - Da Funk.mp3`},
+		},
+		"suffix is a word": {
+			query: "*together",
+			names: []string{`@@music\The Beatles\Abbey Road\01 - Come Together.mp3`},
+		},
+		"unknown suffix": {
+			query: "*zzz",
+		},
+		"whole words": {
+			query: "beat",
+		},
+		"only exclusions": {
+			query: "-beatles",
+		},
+		"limit": {
+			query: "music",
+			limit: 2,
+			names: []string{
+				`@@music\Daft Punk\Discovery\01 - One More Time.flac`,
+				`@@music\Daft Punk\Discovery\02 - Aerodynamic.flac`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var names []string
+			for _, f := range i.search(test.query, test.limit) {
+				names = append(names, f.Name)
+			}
+
+			assert.Equal(t, test.names, names)
+		})
+	}
+}
+
+func TestAutoRespond(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+
+	state2 := login(ctx, t, s, "user2", library(t))
+	state2.client.config.AutoRespond = true
+
+	// Nothing matches, nothing is sent back.
+	results, err := state1.Search(ctx, "flac", soul.NewToken())
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(time.Second):
+
+	case f := <-results:
+		assert.Fail(t, "unexpected result", f.Name)
+	}
+
+	token := soul.NewToken()
+	results, err = state1.Search(ctx, "file_example mp3", token)
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timeout")
+
+	case f := <-results:
+		assert.Equal(t, "user2", f.Username)
+		assert.Equal(t, token, f.Token)
+		assert.Equal(t, "file_example_MP3_700KB.mp3", virtualBase(f.Name))
+		assert.Len(t, f.Attributes, 3)
+		assert.True(t, f.FreeSlot)
+		assert.Zero(t, f.Queue)
+	}
+}
+
+func TestExcludedSearchPhrases(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+	s.ExcludedSearchPhrases = []string{"EXAMPLE_mp3"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+
+	state2 := login(ctx, t, s, "user2", library(t))
+	state2.client.config.AutoRespond = true
+
+	results, err := state1.Search(ctx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(time.Second):
+
+	case f := <-results:
+		assert.Fail(t, "excluded result", f.Name)
+	}
+
+	assert.Eventually(t, func() bool { return state2.ExcludedResults() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The server changes its mind.
+	s.ExcludeSearchPhrases("something else")
+
+	assert.Eventually(t, func() bool {
+		state2.mu.RLock()
+		defer state2.mu.RUnlock()
+
+		return len(state2.excludedPhrases) == 1 && state2.excludedPhrases[0] == "something else"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	results, err = state1.Search(ctx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timeout")
+
+	case f := <-results:
+		assert.Equal(t, "file_example_MP3_700KB.mp3", virtualBase(f.Name))
+	}
+
+	assert.Equal(t, int64(1), state2.ExcludedResults())
+}
+
+func TestExcludedSearchPhrasesLate(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+	s.ExcludedSearchPhrases = []string{"EXAMPLE_mp3"}
+	s.LatePhrases = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The phrases follow the reply that completes the login.
+	state := login(ctx, t, s, "user1", "")
+
+	assert.Eventually(t, func() bool {
+		state.mu.RLock()
+		defer state.mu.RUnlock()
+
+		return len(state.excludedPhrases) == 1 && state.excludedPhrases[0] == "example_mp3"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestExcluded(t *testing.T) {
+	t.Parallel()
+
+	phrases := lower([]string{"Bad Phrase", ""})
+
+	assert.True(t, excluded(phrases, `@@music\a BAD PHRASE here.mp3`))
+	assert.False(t, excluded(phrases, `@@music\a bad-phrase here.mp3`))
+	assert.False(t, excluded(nil, `@@music\a bad phrase here.mp3`))
+}
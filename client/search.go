@@ -0,0 +1,271 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/server"
+)
+
+// sessionResults is the buffer size of SearchSession.Results.
+const sessionResults = 64
+
+// SearchSession collects the responses to a search, grouped by user and folder with what
+// each user told us about its upload slots, see Snapshot. Responses a user sends more
+// than once are counted once.
+type SearchSession struct {
+	Query string
+	Token soul.Token
+	// Results streams every new result as it arrives. It is closed when the session
+	// ends, once its context is done or it collected Config.MaxSessionResults results.
+	Results chan *File
+	// Ranking weighs the results for Best and BestFolder, Config.Ranking unless changed.
+	Ranking Ranking
+
+	users  []*SearchUser
+	byName map[string]*SearchUser
+	seen   map[string]bool
+	// pending are the results not yet sent to Results.
+	pending []*File
+	files   int
+	locked  int
+	max     int
+	full    bool
+	ended   bool
+	wake    chan struct{}
+	mu      sync.Mutex
+}
+
+// SearchUser is what a user responded to a search with.
+type SearchUser struct {
+	Username     string
+	FreeSlot     bool
+	AverageSpeed int
+	// Queue is the number of uploads the user has queued.
+	Queue   int
+	Folders []*SearchFolder
+}
+
+// SearchFolder holds the results in a folder of a user's shares.
+type SearchFolder struct {
+	Name  string
+	Files []*File
+}
+
+// SearchCounts are the sizes of a SearchSession.
+type SearchCounts struct {
+	Users int
+	Files int
+	// Locked is how many of the files are locked, see File.Locked.
+	Locked int
+}
+
+// SearchSession searches the network for query and collects the responses until ctx is
+// done, or Config.MaxSessionResults results arrived.
+func (s *State) SearchSession(ctx context.Context, query string, token soul.Token) (*SearchSession, error) {
+	return s.session(ctx, query, token, func(conn io.Writer) error {
+		_, err := server.Write(conn, &server.FileSearch{Token: token, SearchQuery: query})
+		return err
+	}, true)
+}
+
+// session starts collecting the responses to token and sends the search with write. If
+// restore is set, write sends it again after a reconnect, see Config.Reconnect.
+func (s *State) session(ctx context.Context, query string, token soul.Token, write func(io.Writer) error, restore bool) (*SearchSession, error) {
+	session := newSearchSession(query, token, s.client.config.MaxSessionResults)
+	session.Ranking = s.client.config.Ranking
+
+	s.mu.Lock()
+	s.searches[token] = session
+	if restore {
+		s.queries[token] = write
+	}
+	s.mu.Unlock()
+
+	err := write(s.client.Conn())
+	if err != nil {
+		s.forget(token)
+		return nil, err
+	}
+
+	s.log.Debug().Str(fmt.Sprintf("%v", token), query).Msg("search message sent")
+
+	go func() {
+		session.stream(ctx)
+		s.forget(token)
+	}()
+
+	return session, nil
+}
+
+// forget stops routing the responses to the search with token.
+func (s *State) forget(token soul.Token) {
+	s.mu.Lock()
+	delete(s.searches, token)
+	delete(s.queries, token)
+	s.mu.Unlock()
+}
+
+// newSearchSession returns an empty session capped at max results, zero does not cap.
+func newSearchSession(query string, token soul.Token, max int) *SearchSession {
+	return &SearchSession{
+		Query:   query,
+		Token:   token,
+		Results: make(chan *File, sessionResults),
+		byName:  make(map[string]*SearchUser),
+		seen:    make(map[string]bool),
+		max:     max,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Counts returns how many users responded with how many files so far.
+func (ss *SearchSession) Counts() SearchCounts {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return SearchCounts{Users: len(ss.users), Files: ss.files, Locked: ss.locked}
+}
+
+// Snapshot returns the responses so far, users in the order they responded.
+func (ss *SearchSession) Snapshot() []SearchUser {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	users := make([]SearchUser, 0, len(ss.users))
+	for _, u := range ss.users {
+		c := *u
+		c.Folders = make([]*SearchFolder, 0, len(u.Folders))
+		for _, folder := range u.Folders {
+			c.Folders = append(c.Folders, &SearchFolder{
+				Name:  folder.Name,
+				Files: append([]*File(nil), folder.Files...),
+			})
+		}
+
+		users = append(users, c)
+	}
+
+	return users
+}
+
+// add collects the results of r that are new.
+func (ss *SearchSession) add(r *peer.FileSearchResponse) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.ended || ss.full {
+		return
+	}
+
+	u, found := ss.byName[r.Username]
+	if !found {
+		u = &SearchUser{Username: r.Username}
+		ss.byName[r.Username] = u
+		ss.users = append(ss.users, u)
+	}
+
+	u.FreeSlot, u.AverageSpeed, u.Queue = r.FreeSlot, r.AverageSpeed, r.Queue
+
+	for _, results := range []struct {
+		files  []peer.File
+		locked bool
+	}{{r.Results, false}, {r.PrivateResults, true}} {
+		for _, result := range results.files {
+			if ss.full {
+				break
+			}
+
+			key := r.Username + "\x00" + result.Name
+			if ss.seen[key] {
+				continue
+			}
+
+			ss.seen[key] = true
+
+			f := &File{
+				Username:     r.Username,
+				Token:        r.Token,
+				Queue:        r.Queue,
+				FreeSlot:     r.FreeSlot,
+				AverageSpeed: r.AverageSpeed,
+				Locked:       results.locked,
+				File:         &result,
+			}
+
+			folder := u.folder(virtualDir(result.Name))
+			folder.Files = append(folder.Files, f)
+
+			ss.files++
+			if f.Locked {
+				ss.locked++
+			}
+
+			ss.pending = append(ss.pending, f)
+			ss.full = ss.max > 0 && ss.files >= ss.max
+		}
+	}
+
+	select {
+	case ss.wake <- struct{}{}:
+	default:
+	}
+}
+
+// folder returns the user's folder named name, adding it if it is new.
+func (u *SearchUser) folder(name string) *SearchFolder {
+	for _, folder := range u.Folders {
+		if folder.Name == name {
+			return folder
+		}
+	}
+
+	folder := &SearchFolder{Name: name}
+	u.Folders = append(u.Folders, folder)
+
+	return folder
+}
+
+// stream sends the collected results to Results until ctx is done or the session is
+// full, then closes Results.
+func (ss *SearchSession) stream(ctx context.Context) {
+	defer func() {
+		ss.mu.Lock()
+		ss.ended = true
+		ss.pending = nil
+		ss.mu.Unlock()
+
+		close(ss.Results)
+	}()
+
+	for {
+		ss.mu.Lock()
+		pending, full := ss.pending, ss.full
+		ss.pending = nil
+		ss.mu.Unlock()
+
+		for _, f := range pending {
+			select {
+			case <-ctx.Done():
+				return
+
+			case ss.Results <- f:
+			}
+		}
+
+		if full {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ss.wake:
+		}
+	}
+}
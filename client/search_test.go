@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchSession(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	searchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	session, err := state1.SearchSession(searchCtx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "search timeout")
+
+	case f := <-session.Results:
+		assert.Equal(t, "user2", f.Username)
+		assert.False(t, f.Locked)
+	}
+
+	assert.Equal(t, SearchCounts{Users: 1, Files: 1}, session.Counts())
+
+	users := session.Snapshot()
+	require.Len(t, users, 1)
+	assert.Equal(t, "user2", users[0].Username)
+	require.Len(t, users[0].Folders, 1)
+	assert.Len(t, users[0].Folders[0].Files, 1)
+
+	// The stream is closed once the search is over.
+	stop()
+
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "results not closed")
+
+	case _, ok := <-session.Results:
+		assert.False(t, ok)
+	}
+
+	assert.Eventually(t, func() bool {
+		state1.mu.RLock()
+		defer state1.mu.RUnlock()
+
+		return len(state1.searches) == 0 && len(state1.queries) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSearchSessionAdd(t *testing.T) {
+	t.Parallel()
+
+	session := newSearchSession("track", soul.NewToken(), 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		session.stream(ctx)
+		close(done)
+	}()
+
+	session.add(&peer.FileSearchResponse{
+		Username: "user1",
+		Queue:    3,
+		Results: []peer.File{
+			{Name: `@@music\A\track1.flac`},
+			{Name: `@@music\A\track2.flac`},
+			{Name: `@@music\B\track1.flac`},
+		},
+		PrivateResults: []peer.File{{Name: `@@music\C\track1.flac`}},
+	})
+
+	// The same response again changes nothing but the user's details.
+	session.add(&peer.FileSearchResponse{
+		Username: "user1",
+		FreeSlot: true,
+		Results:  []peer.File{{Name: `@@music\A\track1.flac`}},
+	})
+
+	assert.Equal(t, SearchCounts{Users: 1, Files: 4, Locked: 1}, session.Counts())
+
+	users := session.Snapshot()
+	require.Len(t, users, 1)
+	assert.True(t, users[0].FreeSlot)
+
+	var folders []string
+	for _, folder := range users[0].Folders {
+		folders = append(folders, folder.Name)
+	}
+
+	assert.Equal(t, []string{`@@music\A`, `@@music\B`, `@@music\C`}, folders)
+	assert.True(t, users[0].Folders[2].Files[0].Locked)
+
+	// Once the session is full the stream ends after the results collected so far.
+	session.add(&peer.FileSearchResponse{Username: "user2", Results: []peer.File{{Name: `track1.flac`}, {Name: `track2.flac`}}})
+	session.add(&peer.FileSearchResponse{Username: "user3", Results: []peer.File{{Name: `track1.flac`}}})
+
+	var results []*File
+	for f := range session.Results {
+		results = append(results, f)
+	}
+
+	<-done
+
+	assert.Len(t, results, 5)
+	assert.Equal(t, 3, results[0].Queue)
+	assert.Equal(t, SearchCounts{Users: 2, Files: 5, Locked: 1}, session.Counts())
+}
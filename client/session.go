@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math/rand/v2"
+	"time"
+
+	"github.com/bh90210/soul/server"
+)
+
+// sessionEvents is the buffer size of State.Session. Events are dropped if nobody reads them.
+const sessionEvents = 16
+
+// SessionStatus represents a change in the server session.
+type SessionStatus string
+
+const (
+	// SessionDisconnected indicates that the server connection dropped.
+	SessionDisconnected SessionStatus = "disconnected"
+	// SessionReconnecting indicates that a reconnect attempt failed and another one is scheduled.
+	SessionReconnecting SessionStatus = "reconnecting"
+	// SessionReconnected indicates that we are connected and logged in again.
+	SessionReconnected SessionStatus = "reconnected"
+	// SessionRelogged indicates that the server kicked us because the same user logged in
+	// from somewhere else. No reconnect is attempted.
+	SessionRelogged SessionStatus = "relogged"
+)
+
+// ErrRelogged is reported when the server kicks us because someone logged in with our username.
+var ErrRelogged = errors.New("logged in from another location")
+
+// SessionEvent is sent to State.Session whenever the server session changes.
+type SessionEvent struct {
+	Status SessionStatus
+	// Attempt is the number of reconnect attempts so far.
+	Attempt int
+	// Delay is the wait before the next attempt, set for SessionReconnecting.
+	Delay time.Duration
+	Err   error
+}
+
+// supervise watches the server connection. When it drops it reconnects with exponential
+// backoff and jitter, logs in again and restores active searches and distributed state.
+// A Relogged message stops it for good.
+func (s *State) supervise(ctx context.Context) {
+	relogged := s.client.Relays.Relogged.Listener(1)
+	defer relogged.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-relogged.Ch():
+			s.log.Warn().Msg("relogged, someone else logged in with our username")
+
+			s.event(&SessionEvent{Status: SessionRelogged, Err: ErrRelogged})
+
+			// The server closes the connection anyway. Closing it ourselves makes sure
+			// we do not keep reading from a session that is no longer ours.
+			s.client.Close()
+
+			return
+
+		case d := <-s.client.disconnected:
+			// A connection a reconnect replaced since, ie. that of a failed login.
+			if d.conn != s.client.Conn() {
+				s.log.Debug().Err(d.err).Msg("replaced server connection dropped")
+				continue
+			}
+
+			err := d.err
+			s.log.Warn().Err(err).Msg("server connection dropped")
+
+			s.mu.Lock()
+			s.online = false
+			s.mu.Unlock()
+
+			s.event(&SessionEvent{Status: SessionDisconnected, Err: err})
+
+			if !s.client.config.Reconnect {
+				return
+			}
+
+			if !s.reconnect(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// reconnect keeps trying to reconnect and login until it succeeds or ctx is done.
+func (s *State) reconnect(ctx context.Context) bool {
+	for attempt := 1; ; attempt++ {
+		err := s.client.Reconnect(ctx)
+		if err == nil {
+			err = s.relogin(ctx)
+		}
+
+		if err == nil {
+			err = s.restore(ctx)
+		}
+
+		if err == nil {
+			s.log.Info().Int("attempt", attempt).Msg("reconnected")
+			s.event(&SessionEvent{Status: SessionReconnected, Attempt: attempt})
+			return true
+		}
+
+		delay := backoff(s.client.config.ReconnectBackoff, s.client.config.ReconnectMaxBackoff, attempt)
+
+		s.log.Warn().Err(err).Int("attempt", attempt).Dur("delay", delay).Msg("reconnect")
+		s.event(&SessionEvent{Status: SessionReconnecting, Attempt: attempt, Delay: delay, Err: err})
+
+		select {
+		case <-ctx.Done():
+			return false
+
+		case <-time.After(delay):
+		}
+	}
+}
+
+// relogin logs in over a new connection, bounded by Config.LoginTimeout.
+func (s *State) relogin(ctx context.Context) error {
+	if s.client.config.LoginTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.client.config.LoginTimeout)
+		defer cancel()
+	}
+
+	_, err := s.login(ctx)
+
+	return err
+}
+
+// restore sends the server everything it forgot about us with the old connection:
+// active searches and our position in the distributed network.
+func (s *State) restore(ctx context.Context) error {
+	s.mu.RLock()
+	queries := maps.Clone(s.queries)
+
+	parent, root, level := s.parent, s.root, s.level
+	s.mu.RUnlock()
+
+	for _, write := range queries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := write(s.client.Conn())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Without a parent the login handshake already asked for one.
+	if parent == nil {
+		return nil
+	}
+
+	_, err := server.Write(s.client.Conn(), &server.HaveNoParent{Have: false})
+	if err != nil {
+		return err
+	}
+
+	_, err = server.Write(s.client.Conn(), &server.BranchRoot{Root: root})
+	if err != nil {
+		return err
+	}
+
+	_, err = server.Write(s.client.Conn(), &server.BranchLevel{Level: int(level)})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// event sends e to State.Session without blocking.
+func (s *State) event(e *SessionEvent) {
+	select {
+	case s.Session <- e:
+	default:
+		s.log.Debug().Str("status", string(e.Status)).Msg("session event dropped")
+	}
+}
+
+// backoff returns the delay before the given attempt: base doubled per attempt, capped at
+// max, with up to half of it randomised so that many clients do not reconnect in lockstep.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+
+	delay := base
+	for i := 1; i < attempt && (max <= 0 || delay < max); i++ {
+		delay *= 2
+	}
+
+	if max > 0 && delay > max {
+		delay = max
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bh90210/soul/peer"
+)
+
+// Shared files are advertised with virtual paths instead of local ones, so that peers
+// never learn our filesystem layout. A virtual path is the share name prefixed with @@
+// followed by the path inside the share, separated with backslashes as other SoulSeek
+// clients expect, ie. @@music\Artist\Album\track.flac.
+const (
+	virtualPrefix    = "@@"
+	virtualSeparator = `\`
+)
+
+// shares returns the share names mapped to their local folders: Config.Shares plus
+// Config.Library, if set, named after its base folder.
+func shares(config *Config) map[string]string {
+	shares := make(map[string]string, len(config.Shares)+1)
+	for name, folder := range config.Shares {
+		shares[name] = filepath.Clean(folder)
+	}
+
+	if config.Library != "" {
+		library := filepath.Clean(config.Library)
+
+		name := filepath.Base(library)
+		for i := 2; ; i++ {
+			folder, taken := shares[name]
+			if !taken || folder == library {
+				break
+			}
+
+			name = fmt.Sprintf("%s%d", filepath.Base(library), i)
+		}
+
+		shares[name] = library
+	}
+
+	return shares
+}
+
+// virtualPath returns the virtual path of local, a file inside the folder shared as name.
+func virtualPath(name, folder, local string) (string, error) {
+	rel, err := filepath.Rel(folder, local)
+	if err != nil {
+		return "", err
+	}
+
+	parts := append([]string{virtualPrefix + name}, strings.Split(filepath.ToSlash(rel), "/")...)
+
+	return strings.Join(parts, virtualSeparator), nil
+}
+
+// virtualDir returns the directory of a virtual path.
+func virtualDir(virtual string) string {
+	i := strings.LastIndex(virtual, virtualSeparator)
+	if i < 0 {
+		return virtual
+	}
+
+	return virtual[:i]
+}
+
+// virtualBase returns the last element of a path a peer sent us. Peers use backslash
+// separators, some use slashes.
+func virtualBase(virtual string) string {
+	return virtual[strings.LastIndexAny(virtual, `\/`)+1:]
+}
+
+// virtuals maps the virtual paths of indexed files to their local paths.
+func virtuals(files map[string]indexEntry) map[string]string {
+	virtuals := make(map[string]string, len(files))
+	for local, e := range files {
+		virtuals[e.File.Name] = local
+	}
+
+	return virtuals
+}
+
+// local resolves a virtual path a peer sent us to the local path of a shared file. Only
+// files in the share index resolve, so a peer can never reach anything else on disk.
+func (s *State) local(virtual string) (string, error) {
+	s.mu.RLock()
+	local, found := s.virtuals[virtual]
+	s.mu.RUnlock()
+
+	if !found {
+		return "", fmt.Errorf("%w: %s", peer.ErrFileNotShared, virtual)
+	}
+
+	return local, nil
+}
+
+// folder returns the shared directories at or below the virtual folder a peer asked for.
+func (s *State) folder(virtual string) ([]peer.Directory, error) {
+	virtual = strings.TrimRight(virtual, `\/`)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := make(map[string]indexEntry)
+	for local, e := range s.indexed {
+		if strings.HasPrefix(e.File.Name, virtual+virtualSeparator) {
+			files[local] = e
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s", peer.ErrFileNotShared, virtual)
+	}
+
+	return directories(files), nil
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShares(t *testing.T) {
+	t.Parallel()
+
+	shares := shares(&Config{
+		Library: "/srv/media/music/",
+		Shares: map[string]string{
+			"music":  "/home/user/music",
+			"videos": "/srv/media/videos",
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"music":  "/home/user/music",
+		"music2": "/srv/media/music",
+		"videos": "/srv/media/videos",
+	}, shares)
+}
+
+func TestVirtualPath(t *testing.T) {
+	t.Parallel()
+
+	virtual, err := virtualPath("music", "/srv/media/music", "/srv/media/music/Artist/Album/track.flac")
+	require.NoError(t, err)
+	assert.Equal(t, `@@music\Artist\Album\track.flac`, virtual)
+	assert.Equal(t, `@@music\Artist\Album`, virtualDir(virtual))
+	assert.Equal(t, "track.flac", virtualBase(virtual))
+	assert.Equal(t, "track.flac", virtualBase("Artist/Album/track.flac"))
+
+}
+
+func TestLocal(t *testing.T) {
+	t.Parallel()
+
+	dir := library(t)
+
+	s := &State{shares: map[string]string{"music": dir}}
+
+	var err error
+	s.indexed, err = index(context.Background(), s.shares, nil, zerolog.Nop())
+	require.NoError(t, err)
+
+	s.virtuals = virtuals(s.indexed)
+
+	local, err := s.local(`@@music\file_example_MP3_700KB.mp3`)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "file_example_MP3_700KB.mp3"), local)
+
+	for _, virtual := range []string{
+		filepath.Join(dir, "file_example_MP3_700KB.mp3"),
+		`@@music\..\..\etc\passwd`,
+		`@@music\sub\..\file_example_MP3_700KB.mp3`,
+		`@@videos\file_example_MP3_700KB.mp3`,
+		`@@music`,
+	} {
+		_, err = s.local(virtual)
+		assert.ErrorIs(t, err, peer.ErrFileNotShared, virtual)
+	}
+}
+
+func TestFolder(t *testing.T) {
+	t.Parallel()
+
+	dir := library(t)
+
+	s := &State{shares: map[string]string{"music": filepath.Dir(dir)}}
+
+	var err error
+	s.indexed, err = index(context.Background(), s.shares, nil, zerolog.Nop())
+	require.NoError(t, err)
+
+	virtual := `@@music\` + filepath.Base(dir)
+	for _, folder := range []string{virtual, virtual + `\`, `@@music`} {
+		folders, err := s.folder(folder)
+		require.NoError(t, err)
+		require.Len(t, folders, 1)
+		assert.Equal(t, virtual, folders[0].Name)
+		assert.Equal(t, "file_example_MP3_700KB.mp3", folders[0].Files[0].Name)
+	}
+
+	for _, folder := range []string{`@@videos`, `@@mus`, virtual + `\..`} {
+		_, err = s.folder(folder)
+		assert.ErrorIs(t, err, peer.ErrFileNotShared, folder)
+	}
+}
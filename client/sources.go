@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/bh90210/soul"
+)
+
+// ErrNoQuery is returned by Sources for a file name without any word to search for.
+var ErrNoQuery = errors.New("no query")
+
+// Alternatives returns the files in results that are f shared by other users, ie. of the
+// same base name and size, one per user. Locked results are left out, see File.Locked.
+// They are ranked the way a download should try them, see File.Sources: users with a
+// free upload slot first, then by the shortest queue and the highest speed.
+func Alternatives(f *File, results []*File) []*File {
+	base := virtualBase(f.Name)
+	users := map[string]bool{f.Username: true}
+
+	var alternatives []*File
+	for _, r := range results {
+		if r.File == nil || r.Locked || users[r.Username] || r.Size != f.Size || !strings.EqualFold(virtualBase(r.Name), base) {
+			continue
+		}
+
+		users[r.Username] = true
+		alternatives = append(alternatives, r)
+	}
+
+	slices.SortStableFunc(alternatives, func(a, b *File) int {
+		switch {
+		case a.FreeSlot != b.FreeSlot:
+			if a.FreeSlot {
+				return -1
+			}
+
+			return 1
+
+		case a.Queue != b.Queue:
+			return a.Queue - b.Queue
+
+		default:
+			return b.AverageSpeed - a.AverageSpeed
+		}
+	})
+
+	return alternatives
+}
+
+// Sources searches the network for f by its base name until ctx is done and returns the
+// other users sharing it, see Alternatives. Set them as f.Sources before downloading f
+// for the download to fall back on them.
+func (s *State) Sources(ctx context.Context, f *File) ([]*File, error) {
+	query := sourcesQuery(f.Name)
+	if query == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoQuery, f.Name)
+	}
+
+	session, err := s.SearchSession(ctx, query, soul.NewToken())
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*File
+	for r := range session.Results {
+		found = append(found, r)
+	}
+
+	return Alternatives(f, found), nil
+}
+
+// sourcesQuery returns the search query for the file name: the words of its base name.
+// Everything else is left out, so that ie. a dash is not taken for an excluded word.
+func sourcesQuery(name string) string {
+	base := virtualBase(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	return strings.Join(strings.FieldsFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
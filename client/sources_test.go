@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadSources(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	results, err := state1.Search(ctx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	var f *File
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "search timeout")
+
+	case f = <-results:
+	}
+
+	expected, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+	require.NoError(t, err)
+
+	// The user the file was asked from is offline and left part of it on disk.
+	offline := &File{
+		Username: "nobody",
+		Sources:  []*File{f},
+		File:     &peer.File{Name: `@@music\` + virtualBase(f.Name), Size: f.Size},
+	}
+
+	incomplete := incompletePath(state1.client.config, offline)
+	require.NoError(t, os.MkdirAll(filepath.Dir(incomplete), 0755))
+	require.NoError(t, os.WriteFile(incomplete, expected[:1000], 0644))
+
+	transfer, err := state1.Download(ctx, offline)
+	require.NoError(t, err)
+
+	var sources []string
+	var e *TransferEvent
+	for e = range transfer.Events {
+		if len(sources) == 0 || sources[len(sources)-1] != e.Source {
+			sources = append(sources, e.Source)
+		}
+	}
+
+	require.Equal(t, TransferCompleted, e.State, e.Err)
+	assert.Equal(t, []string{"user2"}, sources)
+
+	downloaded, err := os.ReadFile(e.Path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, downloaded)
+	assert.Equal(t, filepath.Join(state1.client.config.DownloadFolder, "music", virtualBase(f.Name)), e.Path)
+}
+
+func TestSources(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	f := &File{Username: "nobody", File: &peer.File{Name: `@@music\file_example_MP3_700KB.mp3`}}
+	state2.mu.RLock()
+	for _, e := range state2.indexed {
+		f.Size = e.File.Size
+	}
+	state2.mu.RUnlock()
+
+	searchCtx, stop := context.WithTimeout(ctx, 2*time.Second)
+	defer stop()
+
+	sources, err := state1.Sources(searchCtx, f)
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	assert.Equal(t, "user2", sources[0].Username)
+}
+
+func TestAlternatives(t *testing.T) {
+	t.Parallel()
+
+	file := func(username, name string, size uint64, free bool, queue, speed int) *File {
+		return &File{
+			Username:     username,
+			FreeSlot:     free,
+			Queue:        queue,
+			AverageSpeed: speed,
+			File:         &peer.File{Name: name, Size: size},
+		}
+	}
+
+	f := file("user1", `@@music\Artist\track.flac`, 100, false, 0, 0)
+
+	locked := file("user9", `@@share\track.flac`, 100, true, 0, 100)
+	locked.Locked = true
+
+	alternatives := Alternatives(f, []*File{
+		file("user1", `@@other\track.flac`, 100, true, 0, 0),
+		file("user2", `@@music\track.flac`, 100, false, 3, 10),
+		file("user3", `@@music\Track.FLAC`, 100, false, 1, 10),
+		file("user4", `@@music\track.flac`, 101, true, 0, 0),
+		file("user5", `@@music\other.flac`, 100, true, 0, 0),
+		file("user6", `@@share\A\track.flac`, 100, true, 0, 5),
+		file("user6", `@@share\B\track.flac`, 100, true, 0, 5),
+		file("user7", `@@share\track.flac`, 100, true, 0, 50),
+		file("user8", `@@share\track.flac`, 100, false, 1, 20),
+		locked,
+	})
+
+	var users []string
+	for _, a := range alternatives {
+		users = append(users, a.Username)
+	}
+
+	assert.Equal(t, []string{"user7", "user6", "user8", "user3", "user2"}, users)
+}
+
+func TestSourcesNoQuery(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := login(ctx, t, s, "user1", "")
+
+	_, err := state.Sources(ctx, &File{Username: "user2", File: &peer.File{Name: `@@music\- .mp3`}})
+	assert.ErrorIs(t, err, ErrNoQuery)
+}
+
+func TestSourcesQuery(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "Artist Album 01 Track", sourcesQuery(`@@music\x\Artist - Album - 01 - Track.flac`))
+	assert.Equal(t, "Ünïcode 2", sourcesQuery(`Ünïcode_2.mp3`))
+}
@@ -6,12 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"net"
 	"os"
-	"path"
 	"path/filepath"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,9 +20,9 @@ import (
 	"github.com/bh90210/soul/file"
 	"github.com/bh90210/soul/peer"
 	"github.com/bh90210/soul/server"
-	"github.com/charlievieth/fastwalk"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/teivah/broadcast"
 )
 
 // HundredKb 100Kb is the size of the buffer for file downloads.
@@ -40,24 +37,64 @@ type QueueUpload struct {
 // State represents the client state.
 type State struct {
 	Incoming chan *Search
+	// Session reports the state of the server connection, see SessionEvent.
+	Session chan *SessionEvent
+	// WishlistHits streams the new results of the wishlist searches, see AddWish. Hits
+	// are dropped if nobody reads them.
+	WishlistHits chan *WishlistHit
 
 	client               *Client
-	searches             map[soul.Token]chan *File
-	peers                map[string]*Peer // TODO: Periodically empty.
+	searches             map[soul.Token]*SearchSession
+	queries              map[soul.Token]func(io.Writer) error // Sends a search again after a reconnect.
+	peers                map[string]*Peer                     // TODO: Periodically empty.
 	addToQueue           chan *QueueUpload
 	queuePositionRequest chan *queuePositionRequest
 	queueSizeRequest     chan chan int
-	mu                   sync.RWMutex
+	responses            chan *Search
+	// pierced are the indirect connections we wait for, by ConnectToPeer token.
+	pierced map[soul.Token]chan *PierceFirewall
+	mu      sync.RWMutex
 
 	connectedP int64
-	connectedF int64
+
+	uploadedBytes int64
+	uploadedTime  int64
+	// bandwidth limits F connections, see Config.Bandwidth.
+	bandwidth *bandwidth
+	// uploadSlots holds a token for every running upload, Config.MaxFileConnections at
+	// most.
+	uploadSlots chan struct{}
+	// privilegedUsers are the users the server told us have privileges, their uploads
+	// go first.
+	privilegedUsers map[string]bool
+
+	// wishes are the wishlist, searched for one at a time every wishlistInterval.
+	wishes            []Wish
+	wishlistInterval  time.Duration
+	wishlistDownloads *DownloadManager
+	// wishHits are the hits already sent to WishlistHits, by query.
+	wishHits map[string]*wishHits
+	wishWake chan struct{}
+	// downloads queues the files of DownloadFolder, see SetDownloads.
+	downloads *DownloadManager
+
+	// excludedResults counts search results dropped because of excludedPhrases.
+	excludedResults int64
+	excludedPhrases []string
 
 	level    int32
 	root     string
 	parent   *Peer
 	children []*Peer
 
-	shared *peer.SharedFileListResponse
+	shared   *peer.SharedFileListResponse
+	shares   map[string]string
+	indexed  map[string]indexEntry
+	virtuals map[string]string
+	// searchIndex answers incoming searches, see Config.AutoRespond.
+	searchIndex *searchIndex
+	rescan      sync.Mutex
+	online      bool
 
 	log zerolog.Logger
 }
@@ -66,159 +103,225 @@ type State struct {
 func NewState(c *Client) *State {
 	s := &State{
 		Incoming:             make(chan *Search),
+		Session:              make(chan *SessionEvent, sessionEvents),
+		WishlistHits:         make(chan *WishlistHit, wishlistHits),
 		client:               c,
-		searches:             make(map[soul.Token]chan *File),
+		searches:             make(map[soul.Token]*SearchSession),
+		queries:              make(map[soul.Token]func(io.Writer) error),
 		peers:                make(map[string]*Peer),
 		addToQueue:           make(chan *QueueUpload),
 		queuePositionRequest: make(chan *queuePositionRequest),
 		queueSizeRequest:     make(chan chan int),
+		responses:            make(chan *Search),
+		pierced:              make(map[soul.Token]chan *PierceFirewall),
 		shared:               &peer.SharedFileListResponse{},
+		bandwidth:            newBandwidth(c.config.Bandwidth),
+		uploadSlots:          make(chan struct{}, max(c.config.MaxFileConnections, 1)),
+		wishHits:             make(map[string]*wishHits),
+		wishWake:             make(chan struct{}, 1),
 	}
 
 	s.log = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	s.log = s.log.Level(c.config.LogLevel)
 
-	// Load library directory.
-	shared := make(map[string][]peer.File, 0)
-	var mu sync.Mutex
-	walkFn := func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
-			return nil // returning the error stops iteration
-		}
-
-		if !d.IsDir() {
-			i, err := d.Info()
-			if err != nil {
-				return err
-			}
+	// Index shared folders, reusing the cached index for files that did not change.
+	s.shares = shares(c.config)
 
-			mu.Lock()
-			shared[filepath.Dir(path)] = append(shared[filepath.Dir(path)], peer.File{
-				Name:       path,
-				Size:       uint64(i.Size()),
-				Extension:  filepath.Ext(d.Name()),
-				Attributes: []peer.Attribute{},
-			})
-			mu.Unlock()
-		}
-
-		return nil
+	cached, err := loadIndex(c.config.StateFolder, s.shares)
+	if err != nil {
+		s.log.Warn().Err(err).Msg("load index")
 	}
 
-	err := fastwalk.Walk(&fastwalk.DefaultConfig, c.config.Library, walkFn)
+	s.indexed, err = index(context.Background(), s.shares, cached, s.log)
 	if err != nil {
 		s.log.Fatal().Err(err).Msg("walk")
 	}
 
-	for k, v := range shared {
-		s.shared.Directories = append(s.shared.Directories, peer.Directory{
-			Name:  k,
-			Files: v,
-		})
+	s.shared.Directories = directories(s.indexed)
+	s.virtuals = virtuals(s.indexed)
+	s.searchIndex = newSearchIndex(s.indexed)
+
+	err = saveIndex(c.config.StateFolder, s.shares, s.indexed)
+	if err != nil {
+		s.log.Warn().Err(err).Msg("save index")
+	}
+
+	s.wishes, err = loadWishlist(c.config.StateFolder)
+	if err != nil {
+		s.log.Warn().Err(err).Msg("load wishlist")
 	}
 
 	return s
 }
 
-// Login sends login message to the server and listens for the responses.
-func (s *State) Login(ctx context.Context) error {
-	lis := s.client.Relays.Login.Listener(0)
-	room := s.client.Relays.RoomList.Listener(0)
-	speed := s.client.Relays.ParentMinSpeed.Listener(0)
-	ratio := s.client.Relays.ParentSpeedRatio.Listener(0)
-	wish := s.client.Relays.WishlistInterval.Listener(0)
-	priv := s.client.Relays.PrivilegedUsers.Listener(0)
-	phrases := s.client.Relays.ExcludedSearchPhrases.Listener(0)
-	ownPriv := s.client.Relays.CheckPrivileges.Listener(0)
-	me := s.client.Relays.WatchUser.Listener(0)
-	ownAddress := s.client.Relays.GetPeerAddress.Listener(0)
-
-	defer func() {
-		lis.Close()
-		room.Close()
-		speed.Close()
-		ratio.Close()
-		wish.Close()
-		priv.Close()
-		phrases.Close()
-		ownPriv.Close()
-		me.Close()
-		ownAddress.Close()
-	}()
-
-	ctxI, cancelI := context.WithTimeout(context.Background(), s.client.config.LoginTimeout)
-	var i atomic.Uint32
-	go func() {
-		for {
-			if i.Load() == 9 {
-				go cancelI()
-				return
-			}
+// LoginResult holds everything the server tells us while logging in.
+type LoginResult struct {
+	// Greet is the server's message of the day.
+	Greet string
+	// IP is our public IP address as seen by the server.
+	IP net.IP
+	// Sum is the MD5 hash of our password, echoed back by the server.
+	Sum string
+	// PrivilegeTimeLeft is how long we remain privileged, zero if we are not.
+	PrivilegeTimeLeft time.Duration
+	// ExcludedSearchPhrases are phrases we must not return search results for, if the
+	// server sent them before the login completed. Not every server sends them, and the
+	// ones sent later are applied all the same.
+	ExcludedSearchPhrases []string
+	// WishlistInterval is how often we are allowed to send a wishlist search.
+	WishlistInterval time.Duration
+	// ParentMinSpeed and ParentSpeedRatio are the distributed network parent settings.
+	ParentMinSpeed   int
+	ParentSpeedRatio int
+	// Rooms is the initial list of chat rooms.
+	Rooms []*server.Room
+	// PrivilegedUsers are the users with privileges at the time of login.
+	PrivilegedUsers []string
+}
 
-			select {
-			case r := <-room.Ch():
-				i.Add(1)
-				s.log.Debug().Int("room", len(r.Rooms)).Uint32("i", i.Load()).Msg("room")
+// Login sends login message to the server and waits for the messages the server sends
+// every client that logs in. It returns as soon as all of them arrived, or with ctx's
+// error if it is done first. A rejected login returns server.ErrInvalidUsername,
+// server.ErrInvalidPass or server.ErrInvalidVersion wrapped.
+// Once logged in, incoming server and peer messages are processed until ctx is done.
+// If Config.Reconnect is set, a dropped server connection is re-dialled and the
+// login handshake replayed, see Session.
+func (s *State) Login(ctx context.Context) (*LoginResult, error) {
+	// The server may send its excluded phrases at any point of the login, and messages
+	// are handled concurrently, so the listener is open before login and handed to server.
+	phrases := s.client.Relays.ExcludedSearchPhrases.Listener(1)
+
+	result, err := s.login(ctx)
+	if err != nil {
+		closeListener(phrases)
+		return nil, err
+	}
 
-			case sp := <-speed.Ch():
-				i.Add(1)
-				s.log.Debug().Int("speed", sp.MinSpeed).Uint32("i", i.Load()).Msg("speed")
+	// Once we are logged in to the server, start processing incoming messages from server and peers.
+	go s.peer(ctx)
+	go s.server(ctx, phrases)
+	go s.queue(ctx)
+	go s.supervise(ctx)
+	go s.responder(ctx)
+	go s.wishlist(ctx)
 
-			case r := <-ratio.Ch():
-				i.Add(1)
-				s.log.Debug().Int("ratio", r.SpeedRatio).Uint32("i", i.Load()).Msg("ratio")
+	return result, nil
+}
 
-			case w := <-wish.Ch():
-				i.Add(1)
-				s.log.Debug().Int("wish", w.Interval).Uint32("i", i.Load()).Msg("wish")
+// ErrLoginIncomplete is returned when ctx is done before the server sent everything
+// it sends after a login.
+var ErrLoginIncomplete = errors.New("login incomplete")
 
-			case p := <-priv.Ch():
-				i.Add(1)
-				s.log.Debug().Int("priv", len(p.Users)).Uint32("i", i.Load()).Msg("priv")
+// login sends the login message followed by the messages the server expects from
+// every client that logs in, and collects the server's replies into a LoginResult.
+func (s *State) login(ctx context.Context) (*LoginResult, error) {
+	lis := s.client.Relays.Login.Listener(1)
+	defer lis.Close()
 
-			case p := <-phrases.Ch():
-				i.Add(1)
-				s.log.Debug().Strs("phrases", p.Phrases).Uint32("i", i.Load()).Msg("phrases")
+	room := s.client.Relays.RoomList.Listener(1)
+	defer room.Close()
 
-			case o := <-ownPriv.Ch():
-				i.Add(1)
-				s.log.Debug().Int("self privilege", o.TimeLeft).Uint32("i", i.Load()).Msg("ownPriv")
+	speed := s.client.Relays.ParentMinSpeed.Listener(1)
+	defer speed.Close()
 
-			case m := <-me.Ch():
-				i.Add(1)
+	ratio := s.client.Relays.ParentSpeedRatio.Listener(1)
+	defer ratio.Close()
 
-				if m.Username != s.client.config.Username {
-					s.log.Warn().Any("not me", m).Uint32("i", i.Load()).Any("me", s.client.config.Username).Send()
-					continue
-				}
+	wish := s.client.Relays.WishlistInterval.Listener(1)
+	defer wish.Close()
 
-				s.log.Debug().Any("me", m).Uint32("i", i.Load()).Send()
+	priv := s.client.Relays.PrivilegedUsers.Listener(1)
+	defer priv.Close()
 
-			case o := <-ownAddress.Ch():
-				i.Add(1)
-				s.log.Debug().Any("own address", o).Uint32("i", i.Load()).Msg("own address")
+	phrases := s.client.Relays.ExcludedSearchPhrases.Listener(1)
+	defer phrases.Close()
 
-			case <-ctxI.Done():
-				go cancelI()
-				return
-			}
-		}
-	}()
+	ownPriv := s.client.Relays.CheckPrivileges.Listener(1)
+	defer ownPriv.Close()
 
 	_, err := server.Write(s.client.Conn(), &server.Login{Username: s.client.config.Username, Password: s.client.config.Password})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	s.log.Debug().Msg("login message sent")
 
-	login := <-lis.Ch()
+	var login *server.Login
+	select {
+	case <-ctx.Done():
+		return nil, errors.Join(ErrLoginIncomplete, ctx.Err())
+
+	case login = <-lis.Ch():
+	}
+
+	if login.Reason != nil {
+		return nil, fmt.Errorf("login: %w", login.Reason)
+	}
 
 	s.log.Info().Str("Greet", login.Greet).Str("IP", login.IP.String()).Msg("login message received")
 
-	// Send the rest of login messages.
-	_, err = server.Write(s.client.Conn(), &server.CheckPrivileges{})
+	result := &LoginResult{
+		Greet: login.Greet,
+		IP:    login.IP,
+		Sum:   login.Sum,
+	}
+
+	err = s.handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Debug().Msg("login messages sent")
+
+	// The server sends these right after a successful login. Our CheckPrivileges is
+	// answered after them, so once all have arrived the login is complete.
+	var rooms, minSpeed, speedRatio, interval, privileged, timeLeft bool
+	for !(rooms && minSpeed && speedRatio && interval && privileged && timeLeft) {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ErrLoginIncomplete, ctx.Err())
+
+		case r := <-room.Ch():
+			result.Rooms, rooms = r.Rooms, true
+
+		case m := <-speed.Ch():
+			result.ParentMinSpeed, minSpeed = m.MinSpeed, true
+
+		case m := <-ratio.Ch():
+			result.ParentSpeedRatio, speedRatio = m.SpeedRatio, true
+
+		case w := <-wish.Ch():
+			result.WishlistInterval, interval = time.Duration(w.Interval)*time.Second, true
+
+		case p := <-priv.Ch():
+			result.PrivilegedUsers, privileged = p.Users, true
+
+		case p := <-phrases.Ch():
+			result.ExcludedSearchPhrases = p.Phrases
+
+		case o := <-ownPriv.Ch():
+			result.PrivilegeTimeLeft, timeLeft = time.Duration(o.TimeLeft)*time.Second, true
+		}
+	}
+
+	s.setPrivileged(result.PrivilegedUsers)
+	s.setWishlistInterval(result.WishlistInterval)
+
+	s.mu.Lock()
+	s.online = true
+	if result.ExcludedSearchPhrases != nil {
+		s.excludedPhrases = lower(result.ExcludedSearchPhrases)
+	}
+	s.mu.Unlock()
+
+	s.log.Debug().Any("result", result).Msg("logged in")
+
+	return result, nil
+}
+
+// handshake sends the messages the server expects from every client after login.
+func (s *State) handshake() error {
+	_, err := server.Write(s.client.Conn(), &server.CheckPrivileges{})
 	if err != nil {
 		return err
 	}
@@ -238,7 +341,7 @@ func (s *State) Login(ctx context.Context) error {
 		return err
 	}
 
-	_, err = server.Write(s.client.Conn(), &server.SharedFoldersFiles{Directories: s.client.config.SharedFolders, Files: s.client.config.SharedFiles})
+	_, err = server.Write(s.client.Conn(), s.sharedFoldersFiles())
 	if err != nil {
 		return err
 	}
@@ -273,138 +376,223 @@ func (s *State) Login(ctx context.Context) error {
 		return err
 	}
 
-	s.log.Debug().Msg("login messages sent")
-
-	<-ctxI.Done()
-
-	// Once we are logged in to the server, start processing incoming messages from server and peers.
-	go s.peer(ctx)
-	go s.server(ctx)
-	go s.queue(ctx)
-
 	return nil
 }
 
 // File represents a file to be downloaded.
 type File struct {
-	Username string
-	Token    soul.Token
-	Queue    int
+	Username     string
+	Token        soul.Token
+	Queue        int
+	FreeSlot     bool
+	AverageSpeed int
+	// Locked is set for results the user only shares with some users, downloading them
+	// is likely denied.
+	Locked bool
+	// Folder is the remote folder the file was listed in by State.FolderContents. Once
+	// downloaded the file keeps its place below that folder.
+	Folder string
+	// Sources are other users sharing the same file, best first. A download falls back
+	// on them in turn when Username fails it or queues it too long, see Alternatives.
+	Sources []*File
 	*peer.File
 }
 
-// Search sends search message to the server and listens for the responses.
+// Search sends search message to the server and streams the results. The channel is
+// closed once ctx is done, see SearchSession for the results grouped by user.
 func (s *State) Search(ctx context.Context, query string, token soul.Token) (results chan *File, err error) {
-	results = make(chan *File)
-
-	s.mu.Lock()
-	s.searches[token] = results
-	s.mu.Unlock()
-
-	s.client.mu.RLock()
-	_, err = server.Write(s.client.Conn(), &server.FileSearch{Token: token, SearchQuery: query})
-	s.client.mu.RUnlock()
+	session, err := s.SearchSession(ctx, query, token)
 	if err != nil {
 		s.log.Warn().Err(err).Msg("search")
-		return
+		return nil, err
 	}
 
-	s.log.Debug().Str(fmt.Sprintf("%v", token), query).Msg("search message sent")
+	return session.Results, nil
+}
 
-	go func() {
-		select {
-		case <-ctx.Done():
-			s.mu.Lock()
-			delete(s.searches, token)
-			s.mu.Unlock()
-			return
-		}
-	}()
+// SearchRoom searches the users who joined room for query, like Search does the whole
+// network.
+func (s *State) SearchRoom(ctx context.Context, room, query string) (results chan *File, err error) {
+	token := soul.NewToken()
+	session, err := s.session(ctx, query, token, func(conn io.Writer) error {
+		_, err := server.Write(conn, &server.RoomSearch{Room: room, Token: token, SearchQuery: query})
+		return err
+	}, true)
+	if err != nil {
+		s.log.Warn().Err(err).Str("room", room).Msg("room search")
+		return nil, err
+	}
 
-	return
+	return session.Results, nil
 }
 
-// Status represents the status of a file transfer.
-type Status string
+// SearchUser searches the files username shares for query, like Search does the whole
+// network.
+func (s *State) SearchUser(ctx context.Context, username, query string) (results chan *File, err error) {
+	token := soul.NewToken()
+	session, err := s.session(ctx, query, token, func(conn io.Writer) error {
+		_, err := server.Write(conn, &server.UserSearch{Username: username, Token: token, SearchQuery: query})
+		return err
+	}, true)
+	if err != nil {
+		s.log.Warn().Err(err).Str("username", username).Msg("user search")
+		return nil, err
+	}
 
-const (
-	// StatusQueued indicates that the file is queued for download.
-	StatusQueued Status = "queued"
-	// StatusStarting indicates that the file is starting to download.
-	StatusStarting Status = "starting"
-	// StatusReceived indicates that the file has been received.
-	StatusReceived Status = "received"
-)
+	return session.Results, nil
+}
 
-// ErrNoPeer is returned when the peer is not found.
+// ErrNoPeer is reported when the server does not give us a peer's address.
 var ErrNoPeer = errors.New("no peer")
 
-// Download sends download message to the server and listens for the responses.
-func (s *State) Download(ctx context.Context, f *File) (status chan string, e chan error) {
-	// Try find the the username of the file to download among the peers.
-	s.mu.RLock()
-	p, found := s.peers[f.Username]
-	s.mu.RUnlock()
-
-	status = make(chan string, 10)
-	e = make(chan error, 1)
+// Download asks the peer for f and downloads it to Config.IncompleteFolder, resuming a
+// partially downloaded file. Once complete the file is moved to Config.DownloadFolder,
+// see Config.DownloadLayout and Config.DownloadCollision. Progress is reported on the
+// returned Transfer's Events. The transfer stops when ctx is done or with Transfer.Cancel.
+// f needs no more than a username and a file name: without a P connection to the user,
+// one is opened first, see ErrNoPeer. Should the user fail the download, it carries on
+// from f.Sources, resuming what is on disk, see Config.MaxSourceQueue.
+func (s *State) Download(ctx context.Context, f *File) (*Transfer, error) {
+	if f.Username == "" {
+		return nil, ErrNoUsername
+	}
 
-	// If the peer is not found, return an error.
-	if !found {
-		e <- ErrNoPeer
-		return
+	if f.File == nil || f.Name == "" {
+		return nil, ErrNoFiles
 	}
 
-	go s.download(ctx, p, f, status, e)
+	t, ctx := newTransfer(ctx, f)
 
-	return
+	go s.download(ctx, t)
+
+	return t, nil
 }
 
-func (s *State) download(ctx context.Context, p *Peer, f *File, status chan string, e chan error) {
+// download downloads t's file from its user and, should that fail, from its sources in
+// turn, until one completes it.
+func (s *State) download(ctx context.Context, t *Transfer) {
+	sources := append([]*File{t.File}, t.File.Sources...)
+
+	for i, f := range sources {
+		if i > 0 {
+			t.source(f.Username)
+		}
+
+		err := s.attempt(ctx, t, f, i < len(sources)-1)
+		switch {
+		case t.State().State.Final():
+			return
+
+		case ctx.Err() != nil:
+			t.done(ctx)
+			return
+
+		case i == len(sources)-1:
+			t.fail(err)
+			return
+		}
+
+		s.log.Debug().Err(err).Str("username", f.Username).Str("filename", f.Name).
+			Str("next", sources[i+1].Username).Msg("download source")
+	}
+}
+
+// ErrLongQueue is reported when a download leaves a user that queues it too long for the
+// next of its sources, see Config.MaxSourceQueue.
+var ErrLongQueue = errors.New("queue too long")
+
+// attempt downloads t's file from f, one of its sources, into the file's incomplete
+// path. It returns why f did not complete the transfer, if it ended. With next, the
+// attempt gives up on a long queue, see Config.MaxSourceQueue.
+func (s *State) attempt(ctx context.Context, t *Transfer, f *File, next bool) error {
+	// A denied or failed upload ends the attempt, which closes its listeners so that
+	// they do not hold up later messages from the same peer.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Open a P connection to the user unless there is one.
+	p, err := s.open(ctx, f.Username)
+	if err != nil {
+		return err
+	}
+
 	// Init peer listeners relating to the file transfer.
 	tRequest := p.Relays.TransferRequest.Listener(1)
-	defer tRequest.Close()
+	defer closeListener(tRequest)
 
 	failed := p.Relays.UploadFailed.Listener(1)
-	defer failed.Close()
+	defer closeListener(failed)
 
 	denied := p.Relays.UploadDenied.Listener(1)
-	defer denied.Close()
+	defer closeListener(denied)
 
 	placeInQueue := p.Relays.PlaceInQueueResponse.Listener(1)
-	defer p.Relays.PlaceInQueueResponse.Close()
+	defer closeListener(placeInQueue)
+
+	// The file is kept in the incomplete folder until all of it is on disk, then moved
+	// to its destination, once, by whichever of the transfer or an UploadFailed for an
+	// already complete file gets there first.
+	// Every source downloads into the same incomplete file, named after the file as it
+	// was asked for.
+	config := s.client.config
+	incomplete := incompletePath(config, t.File)
+	complete := sync.OnceValues(func() (string, error) {
+		return move(incomplete, destination(config, t.File), config.DownloadCollision)
+	})
 
 	go func() {
+
 		for {
 			select {
 			case <-ctx.Done():
-				e <- errors.New("context done")
 				return
 
-			case <-failed.Ch():
-				info, err := os.Stat(path.Join(s.client.config.DownloadFolder, f.Name))
+			case m, ok := <-failed.Ch():
+				// The listener was closed, the download is over.
+				if !ok {
+					return
+				}
+
+				if m.Filename != f.Name {
+					continue
+				}
+
+				info, err := os.Stat(incomplete)
 				if err != nil {
 					if !os.IsNotExist(err) {
-						e <- err
+						cancel(err)
 						return
 					}
 				}
 
 				if info != nil {
 					if info.Size() == int64(f.Size) {
-						e <- peer.ErrComplete
+						dest, err := complete()
+						if err != nil {
+							cancel(err)
+							return
+						}
+
+						t.event(&TransferEvent{State: TransferCompleted, Bytes: info.Size(), Path: dest})
+						cancel(nil)
 						return
 					}
 				}
 
-				e <- errors.New("failed")
+				cancel(fmt.Errorf("%w: upload failed", peer.ErrFileReadError))
 				return
 
-			case m := <-denied.Ch():
-				if m != nil {
-					e <- m.Reason
+			case m, ok := <-denied.Ch():
+				if !ok {
 					return
 				}
+
+				if m.Filename != f.Name {
+					continue
+				}
+
+				cancel(m.Reason)
+				return
 			}
 		}
 	}()
@@ -417,23 +605,39 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 	conn, obfuscated := p.Conn(peer.ConnectionType)
 	if conn == nil {
 		sl.Warn().Msg("no connection")
-		e <- errors.New("no connection")
-		return
+		return fmt.Errorf("%w: %s", ErrNoConnection, f.Username)
 	}
 
-	_, err := peer.Write(conn, &peer.QueueUpload{Filename: f.Name}, obfuscated)
+	t.event(&TransferEvent{State: TransferRequested})
+
+	_, err = peer.Write(conn, &peer.QueueUpload{Filename: f.Name}, obfuscated)
 	if err != nil {
-		e <- err
-		return
+		return err
 	}
 
-	status <- string(StatusQueued)
-
 	// Send a place queue request.
 	_, err = peer.Write(conn, &peer.PlaceInQueueRequest{Filename: f.Name}, obfuscated)
 
 	sl.Debug().Msg("waiting transfer request")
 
+	// Ask for our place in the queue again every so often.
+	var placeInQueueTicker <-chan time.Time
+	if s.client.config.PlaceInQueueInterval > 0 {
+		ticker := time.NewTicker(s.client.config.PlaceInQueueInterval)
+		defer ticker.Stop()
+
+		placeInQueueTicker = ticker.C
+	}
+
+	// Give up on the user after a while in its queue if another source follows.
+	var queueTimeout <-chan time.Time
+	if next && config.SourceQueueTimeout > 0 {
+		timer := time.NewTimer(config.SourceQueueTimeout)
+		defer timer.Stop()
+
+		queueTimeout = timer.C
+	}
+
 	// When peer is ready to start the file transfer, it sends a transfer request.
 	var transfer *peer.TransferRequest
 	for {
@@ -441,8 +645,17 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 
 		select {
 		case <-ctx.Done():
-			sl.Warn().Msg("context done")
-			return
+			sl.Debug().Msg("context done")
+			return context.Cause(ctx)
+
+		case <-queueTimeout:
+			return fmt.Errorf("%w: queued for %v", ErrLongQueue, config.SourceQueueTimeout)
+
+		case <-placeInQueueTicker:
+			_, err = peer.Write(conn, &peer.PlaceInQueueRequest{Filename: f.Name}, obfuscated)
+			if err != nil {
+				sl.Debug().Err(err).Msg("place in queue request")
+			}
 
 		case piq := <-placeInQueue.Ch():
 			sl.Debug().Msg("place in queue")
@@ -450,7 +663,11 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 				continue
 			}
 
-			status <- fmt.Sprint(piq.Place)
+			t.queued(int(piq.Place))
+
+			if next && config.MaxSourceQueue > 0 && int(piq.Place) > config.MaxSourceQueue {
+				return fmt.Errorf("%w: place %d", ErrLongQueue, piq.Place)
+			}
 
 		case transfer = <-tRequest.Ch():
 			if transfer.Filename != f.Name {
@@ -466,6 +683,11 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 		}
 	}
 
+	// Other downloads from the peer wait for their requests on the same relay.
+	closeListener(tRequest)
+
+	t.event(&TransferEvent{State: TransferInitializing})
+
 	sl.Debug().Msg("transfer response")
 
 	// We reply to the transfer request with a transfer response.
@@ -474,20 +696,21 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 		Allowed: true,
 	}, obfuscated)
 	if err != nil {
-		e <- err
-		return
+		return err
 	}
 
-	filepath := path.Join(s.client.config.DownloadFolder, path.Base(f.Name))
+	sl.Debug().Str("path", incomplete).Msg("transfer response sent")
 
-	sl.Debug().Str("path", filepath).Msg("transfer response sent")
+	err = os.MkdirAll(filepath.Dir(incomplete), 0755)
+	if err != nil {
+		return err
+	}
 
-	// Stat for the destination file.
-	info, err := os.Stat(filepath)
+	// Stat for the incomplete file.
+	info, err := os.Stat(incomplete)
 	if err != nil {
 		if !os.IsNotExist(err) {
-			e <- err
-			return
+			return err
 		}
 	}
 
@@ -496,56 +719,64 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 	if os.IsNotExist(err) {
 		sl.Debug().Msg("file does not exist")
 
-		localFile, err = os.Create(filepath)
+		localFile, err = os.Create(incomplete)
 		if err != nil {
 			sl.Debug().Msg(err.Error())
-			e <- err
-			return
+			return err
 		}
 
 		defer localFile.Close()
 
 		info, err = localFile.Stat()
 		if err != nil {
-			e <- err
-			return
+			return err
 		}
 
 	} else {
 		// If file exists count the length and pass it to the offset.
 		sl.Debug().Msg("file exists")
 
-		localFile, err = os.OpenFile(filepath, os.O_RDWR, 0644)
+		localFile, err = os.OpenFile(incomplete, os.O_RDWR, 0644)
 		if err != nil {
-			e <- err
-			return
+			return err
 		}
 
 		defer localFile.Close()
 
 		info, err = localFile.Stat()
 		if err != nil {
-			e <- err
-			return
+			return err
+		}
+
+		// A file larger than the one asked for is not a part of it, start over.
+		if info.Size() > int64(f.Size) {
+			sl.Debug().Int64("size", info.Size()).Msg("file larger than expected")
+
+			err = localFile.Truncate(0)
+			if err != nil {
+				return err
+			}
+
+			info, err = localFile.Stat()
+			if err != nil {
+				return err
+			}
 		}
 
 		_, err = localFile.Seek(0, io.SeekEnd)
 		if err != nil {
-			e <- err
-			return
+			return err
 		}
 	}
 
 	s.log.Debug().Any("info", info).Msg("file info")
 
-	status <- string(StatusStarting)
-
 	var fileConn net.Conn
 	for {
 		select {
 		case <-ctx.Done():
-			e <- errors.New("context done before file F connection")
-			return
+			sl.Debug().Msg("context done before file F connection")
+			return context.Cause(ctx)
 
 		default:
 			fileConn, _ = p.Conn(file.ConnectionType, transfer.Token)
@@ -555,6 +786,7 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 		}
 
 		if fileConn != nil {
+			fileConn = s.bandwidth.conn(fileConn, f.Username)
 			defer fileConn.Close()
 			break
 		}
@@ -562,42 +794,64 @@ func (s *State) download(ctx context.Context, p *Peer, f *File, status chan stri
 		sl.Debug().Msg("waiting for file connection")
 	}
 
+	// Closing the file connection unblocks reading from it once the transfer is cancelled.
+	go func() {
+		<-ctx.Done()
+		fileConn.Close()
+	}()
+
 	sl.Debug().Int64("offset", info.Size()).Msg("sending offset")
 
 	_, err = file.Write(fileConn, &file.Offset{Offset: uint64(info.Size())})
 	if err != nil {
-		e <- err
-		return
+		return err
 	}
 
 	sl.Debug().Msg("offset sent")
 
+	t.progress(info.Size(), 0)
+
 	var readSoFar int64
-	for {
+	for info.Size()+readSoFar < int64(f.Size) {
 		n, err := io.CopyN(localFile, fileConn, HundredKb)
+		readSoFar += n
+
 		if err != nil && !errors.Is(err, io.EOF) {
-			e <- err
-			return
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+
+			return err
 		}
 
 		if errors.Is(err, io.EOF) {
 			break
 		}
 
-		readSoFar += n
+		t.progress(info.Size()+readSoFar, readSoFar)
+	}
 
-		if readSoFar == int64(f.Size) {
-			break
-		}
+	sl.Debug().Msg("CopyN exited")
 
-		status <- fmt.Sprintf("%v%%", readSoFar*100/int64(f.Size))
+	if info.Size()+readSoFar < int64(f.Size) {
+		return io.ErrUnexpectedEOF
 	}
 
-	sl.Debug().Msg("CopyN exited")
+	err = localFile.Close()
+	if err != nil {
+		return err
+	}
+
+	dest, err := complete()
+	if err != nil {
+		return err
+	}
 
-	status <- string(StatusReceived)
+	sl.Debug().Str("path", dest).Msg("download complete")
 
-	return
+	t.event(&TransferEvent{State: TransferCompleted, Bytes: info.Size() + readSoFar, Path: dest})
+
+	return nil
 }
 
 // ErrNoFiles is returned when no files are found.
@@ -609,7 +863,11 @@ var ErrNoUsername = errors.New("no username")
 // ErrNoToken is returned when no token is found.
 var ErrNoToken = errors.New("no token")
 
-// Respond sends a response to the search request.
+// ErrExcluded is returned when every result contains a phrase the server excluded.
+var ErrExcluded = errors.New("all results excluded")
+
+// Respond sends a response to the search request. Results containing a phrase the
+// server excluded are dropped, see ExcludedResults.
 func (s *State) Respond(ctx context.Context, files []*File) error {
 	s.log.Debug().Any("files", files).Msg("responding")
 
@@ -627,256 +885,101 @@ func (s *State) Respond(ctx context.Context, files []*File) error {
 		return ErrNoToken
 	}
 
-	var results []peer.File
-	for _, f := range files {
-		results = append(results, *f.File)
-	}
-
-	conn, obfuscated, err := s.connect(ctx, username, token)
-	if err != nil {
-		s.log.Warn().Err(err).Msg("respond connect")
-		return err
-	}
-
-	// Peers may or may not have an active peer type connection at the time of this request.
-	// So we need to account for that. We will be trying to reconnect until context is done or
-	// an unrecoverable error occurs.
-	for {
-		select {
-		case <-ctx.Done():
-			return errors.New("context done")
-
-		default:
-			// Try sending the response to peer.
-			_, err := peer.Write(conn, &peer.FileSearchResponse{
-				Username: s.client.config.Username,
-				Token:    token,
-				Results:  results,
-				FreeSlot: true,
-				Queue:    0,
-			}, obfuscated)
-			if err != nil {
-				return fmt.Errorf("file search response: %w", err)
-			}
-
-			return nil
-		}
-	}
-}
-
-// TODO: polish it (duplicate code etc.)
-func (s *State) connect(ctx context.Context, username string, token soul.Token) (net.Conn, bool, error) {
-	// Search for peer.
+	// The network requires that we never send results containing excluded phrases.
 	s.mu.RLock()
-	p, ok := s.peers[username]
+	phrases := s.excludedPhrases
 	s.mu.RUnlock()
 
-	// If the peer is not found try to connect.
-	if !ok {
-		// Open a GetPeerAddress listener.
-		gpa := s.client.Relays.GetPeerAddress.Listener(1)
-		defer gpa.Close()
-
-		// Let the server know the username we need the address for.
-		_, err := server.Write(s.client.Conn(), &server.GetPeerAddress{Username: username})
-		if err != nil {
-			return nil, false, err
-		}
-
-		// The listener may receive multiple addresses,
-		// so we need to find the one that matches the username.
-		var address *server.GetPeerAddress
-		for {
-			var found bool
-			select {
-			case <-ctx.Done():
-				return nil, false, errors.New("context done")
-
-			case a := <-gpa.Ch():
-				if a.Username != username {
-					continue
-				}
-
-				address = a
-				found = true
-				break
-			}
-
-			if found {
-				break
-			}
-		}
-
-		var port int
-		if address.ObfuscatedPort != 0 {
-			port = address.ObfuscatedPort
-		} else {
-			port = address.Port
+	var results []peer.File
+	for _, f := range files {
+		if excluded(phrases, f.Name) {
+			atomic.AddInt64(&s.excludedResults, 1)
+			continue
 		}
 
-		conn, err := net.Dial("tcp", fmt.Sprintf("%s:%v", address.IP.String(), port))
-		if err != nil {
-			s.log.Warn().Err(err).Msg("respond direct connection")
-
-			for {
-				_, err = server.Write(s.client.Conn(), &server.ConnectToPeer{
-					Token:    token,
-					Username: username,
-					Type:     peer.ConnectionType,
-				})
-				if err != nil {
-					s.log.Warn().Err(err).Msg("respond indirect connection")
-					return nil, false, err
-				}
-
-				s.log.Debug().Msg("waiting for peer to connect")
-
-				var connected bool
-				select {
-				case <-ctx.Done():
-					return nil, false, errors.New("context done")
-
-				default:
-					s.mu.RLock()
-					p, ok = s.peers[username]
-					s.mu.RUnlock()
-
-					if ok {
-						connected = true
-						break
-					}
-				}
-
-				if connected {
-					break
-				}
+		results = append(results, *f.File)
+	}
 
-				time.Sleep(5 * time.Second)
-			}
-		} else {
-			s.mu.Lock()
-			p = NewPeer(s.client.config, &peer.PeerInit{
-				Username:       username,
-				ConnectionType: peer.ConnectionType,
-			})
+	if len(results) == 0 {
+		return ErrExcluded
+	}
 
-			p.ip = address.IP
-			p.port = address.Port
-			p.obfuscatedPort = address.ObfuscatedPort
+	queue, err := s.queueLength(ctx)
+	if err != nil {
+		return err
+	}
 
-			s.peers[p.username] = p
-			s.mu.Unlock()
+	conn, obfuscated, err := s.connect(ctx, username)
+	if err != nil {
+		s.log.Warn().Err(err).Msg("respond connect")
+		return err
+	}
 
-			s.initializers(ctx, peer.ConnectionType, p, conn, address.ObfuscatedPort != 0, s.log)
+	// Peers may or may not have an active peer type connection at the time of this request.
+	// So we need to account for that. We will be trying to reconnect until context is done or
+	// an unrecoverable error occurs.
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.New("context done")
 
-			_, err = peer.Write(conn, &peer.PeerInit{
-				Username:       s.client.config.Username,
-				ConnectionType: peer.ConnectionType,
-			}, address.ObfuscatedPort != 0)
+		default:
+			// Try sending the response to peer.
+			_, err := peer.Write(conn, &peer.FileSearchResponse{
+				Username:     s.client.config.Username,
+				Token:        token,
+				Results:      results,
+				FreeSlot:     queue == 0 && s.freeSlot(),
+				AverageSpeed: s.averageSpeed(),
+				Queue:        queue,
+			}, obfuscated)
 			if err != nil {
-				return nil, false, err
+				return fmt.Errorf("file search response: %w", err)
 			}
+
+			return nil
 		}
 	}
+}
+
+// connect returns the P connection to username, dialling the peer if there is none.
+func (s *State) connect(ctx context.Context, username string) (net.Conn, bool, error) {
+	p, err := s.open(ctx, username)
+	if err != nil {
+		return nil, false, err
+	}
 
-	// TODO: finish checking if connection is active and retrying for {}.
 	conn, obfuscated := p.Conn(peer.ConnectionType)
-	// This should not happen at this stage.
-	// Nevertheless, we need to check if the connection is nil.
-	// TODO: if nil try reconnecting to peer.
 	if conn == nil {
-		return nil, false, errors.New("connection nill")
+		return nil, false, fmt.Errorf("%w: %s", ErrNoConnection, username)
 	}
 
-	ui := p.Relays.UserInfoResponse.Listener(1)
-	defer ui.Close()
-
-	for {
-		s.log.Info().Msg("waiting for user info response")
-		_, err := peer.Write(conn, &peer.UserInfoRequest{}, obfuscated)
-		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
-				s.log.Warn().Msg("connection closed, trying to reconnect")
-				// We try to directly reconnect to peer.
-				// Open a GetPeerAddress listener.
-				gpa := s.client.Relays.GetPeerAddress.Listener(1)
-				defer gpa.Close()
-
-				// Let the server know the username we need the address for.
-				_, err := server.Write(s.client.Conn(), &server.GetPeerAddress{Username: username})
-				if err != nil {
-					return nil, false, err
-				}
-
-				// The listener may receive multiple addresses,
-				// so we need to find the one that matches the username.
-				var address *server.GetPeerAddress
-				for {
-					s.log.Info().Msg("waiting for peer address")
-
-					var found bool
-					select {
-					case <-ctx.Done():
-						return nil, false, errors.New("context done")
-
-					case a := <-gpa.Ch():
-						if a.Username != username {
-							continue
-						}
-
-						address = a
-						found = true
-						break
-					}
-
-					if found {
-						break
-					}
-				}
-
-				var port int
-				if address.ObfuscatedPort != 0 {
-					port = address.ObfuscatedPort
-				} else {
-					port = address.Port
-				}
+	return conn, obfuscated, nil
+}
 
-				s.log.Debug().Msg("peer address found")
+// address asks the server for the address of username.
+func (s *State) address(ctx context.Context, username string) (*server.GetPeerAddress, error) {
+	gpa := s.client.Relays.GetPeerAddress.Listener(1)
+	defer gpa.Close()
 
-				conn, err = net.Dial("tcp", fmt.Sprintf("%s:%v", address.IP.String(), port))
-				if err != nil {
-					s.log.Warn().Err(err).Msg("respond direct connection")
-					return nil, false, err
-				}
+	_, err := server.Write(s.client.Conn(), &server.GetPeerAddress{Username: username})
+	if err != nil {
+		return nil, err
+	}
 
-				s.initializers(ctx, peer.ConnectionType, p, conn, address.ObfuscatedPort != 0, s.log)
+	// The listener may receive multiple addresses,
+	// so we need to find the one that matches the username.
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
 
-				_, err = peer.Write(conn, &peer.PeerInit{
-					Username:       s.client.config.Username,
-					ConnectionType: peer.ConnectionType,
-				}, address.ObfuscatedPort != 0)
-				if err != nil {
-					return nil, false, err
-				}
-			} else {
-				return nil, false, fmt.Errorf("file search response: %w", err)
+		case a := <-gpa.Ch():
+			if a.Username == username {
+				return a, nil
 			}
-		} else {
-			break
 		}
 	}
-
-	s.log.Debug().Msg("user info response sent, now waiting for response")
-
-	select {
-	case <-ctx.Done():
-		return nil, false, errors.New("context done")
-
-	case <-ui.Ch():
-		s.log.Debug().Msg("user info response received")
-	}
-
-	return conn, obfuscated, nil
 }
 
 // peer covers the three ways peers can start a connection with us.
@@ -893,6 +996,11 @@ func (s *State) peer(ctx context.Context) {
 		case firewall := <-s.client.Firewall:
 			s.log.Debug().Any("firewall", firewall).Msg("firewall")
 
+			if !s.firewall(firewall) {
+				s.log.Debug().Uint32("token", uint32(firewall.Token)).Msg("unexpected firewall")
+				firewall.Conn.Close()
+			}
+
 		// Peer directly connects to us.
 		case init := <-s.client.Init:
 			go func(init *PeerInit) {
@@ -986,9 +1094,7 @@ func (s *State) peer(ctx context.Context) {
 					cl.Debug().Msg("peer added")
 				}
 
-				p.ip = connect.IP
-				p.port = connect.Port
-				p.obfuscatedPort = connect.ObfuscatedPort
+				p.setAddress(connect.IP, connect.Port, connect.ObfuscatedPort)
 
 				s.peers[p.username] = p
 				s.mu.Unlock()
@@ -999,7 +1105,9 @@ func (s *State) peer(ctx context.Context) {
 	}
 }
 
-func (s *State) server(ctx context.Context) {
+// server handles the messages the server sends once we are logged in. phrases is the
+// ExcludedSearchPhrases listener opened before login, server closes it.
+func (s *State) server(ctx context.Context, phrases *broadcast.Listener[*server.ExcludedSearchPhrases]) {
 	statusListener := s.client.Relays.GetUserStatus.Listener(1)
 	defer statusListener.Close()
 
@@ -1024,34 +1132,57 @@ func (s *State) server(ctx context.Context) {
 	reset := s.client.Relays.ResetDistributed.Listener(1)
 	defer reset.Close()
 
-	// TODO: Relogged code 41. ParentMinSpeed code 83. ParentSpeedRatio code 84.
+	defer phrases.Close()
+
+	privileged := s.client.Relays.PrivilegedUsers.Listener(1)
+	defer privileged.Close()
+
+	wishlist := s.client.Relays.WishlistInterval.Listener(1)
+	defer wishlist.Close()
+
+	// TODO: ParentMinSpeed code 83. ParentSpeedRatio code 84.
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
-		case status := <-statusListener.Ch():
+		case p := <-phrases.Ch():
+			s.log.Debug().Strs("phrases", p.Phrases).Msg("excluded search phrases")
+
 			s.mu.Lock()
-			defer s.mu.Unlock()
+			s.excludedPhrases = lower(p.Phrases)
+			s.mu.Unlock()
+
+		case p := <-privileged.Ch():
+			s.setPrivileged(p.Users)
 
+		case w := <-wishlist.Ch():
+			s.setWishlistInterval(time.Duration(w.Interval) * time.Second)
+
+		case status := <-statusListener.Ch():
+			s.mu.Lock()
 			p, ok := s.peers[status.Username]
 			if ok {
 				p.status = status.Status
 				p.privileged = status.Privileged
-			} else {
+			}
+			s.mu.Unlock()
+
+			if !ok {
 				s.log.Warn().Str("status", status.Status.String()).Str("username", status.Username).Msg("peer not found")
 			}
 
 		case stats := <-statsListener.Ch():
 			s.mu.Lock()
-			defer s.mu.Unlock()
-
 			p, ok := s.peers[stats.Username]
 			if ok {
 				p.averageSpeed = stats.Speed
 				p.queued = stats.Uploads
-			} else {
+			}
+			s.mu.Unlock()
+
+			if !ok {
 				s.log.Warn().Any("stats", stats).Msg("peer not found")
 			}
 
@@ -1087,14 +1218,15 @@ func (s *State) server(ctx context.Context) {
 			s.log.Debug().Any("watch", watch).Msg("watch")
 
 			s.mu.Lock()
-			defer s.mu.Unlock()
-
 			p, ok := s.peers[watch.Username]
 			if ok {
 				p.status = watch.Status
 				p.averageSpeed = watch.AverageSpeed
 				p.queued = watch.UploadNumber
-			} else {
+			}
+			s.mu.Unlock()
+
+			if !ok {
 				s.log.Warn().Any("watch", watch).Msg("peer not found")
 			}
 
@@ -1154,7 +1286,7 @@ func (s *State) server(ctx context.Context) {
 func (s *State) queue(ctx context.Context) {
 	pl := s.log.With().Str("process", "upload queue").Logger()
 
-	var queue []*QueueUpload
+	queue := newUploadQueue(s.privileged)
 	var mu sync.RWMutex
 
 	go func() {
@@ -1166,29 +1298,31 @@ func (s *State) queue(ctx context.Context) {
 			case que := <-s.addToQueue:
 				pl.Debug().Any("queue", que).Msg("add to queue")
 
-				var alreadyInQueue bool
-				mu.Lock()
-				for _, v := range queue {
-					if v.Filename == que.Filename && v.Peer.username == que.Peer.username {
-						alreadyInQueue = true
-						break
-					}
+				var size int64
+				if local, err := s.local(que.Filename); err == nil {
+					size = s.size(local)
 				}
 
-				if !alreadyInQueue {
-					queue = append(queue, que)
-				}
+				config := s.client.config
+
+				mu.Lock()
+				err := queue.add(que, size, config.MaxQueuedFilesPerUser, config.MaxQueuedMegabytesPerUser)
 				mu.Unlock()
 
+				if err != nil {
+					pl.Debug().Err(err).Any("queue", que).Msg("upload denied")
+
+					go func(que *QueueUpload, reason error) {
+						err := s.deny(que.Peer, que.Filename, reason)
+						if err != nil {
+							pl.Warn().Err(err).Msg("upload denied")
+						}
+					}(que, err)
+				}
+
 			case piq := <-s.queuePositionRequest:
-				var position int
 				mu.RLock()
-				for i, file := range queue {
-					if file.Filename == piq.filename && file.Peer.username == piq.username {
-						position = i + 1
-						break
-					}
-				}
+				position := queue.position(piq.username, piq.filename)
 				mu.RUnlock()
 
 				if position == 0 {
@@ -1203,7 +1337,9 @@ func (s *State) queue(ctx context.Context) {
 				}
 
 			case replyChannel := <-s.queueSizeRequest:
-				replyChannel <- len(queue)
+				mu.RLock()
+				replyChannel <- queue.len()
+				mu.RUnlock()
 			}
 		}
 	}()
@@ -1215,7 +1351,7 @@ func (s *State) queue(ctx context.Context) {
 
 		default:
 			mu.RLock()
-			noQueue := len(queue) == 0
+			noQueue := queue.len() == 0
 			mu.RUnlock()
 
 			if noQueue {
@@ -1223,26 +1359,48 @@ func (s *State) queue(ctx context.Context) {
 				continue
 			}
 
-			// Check if we reached the max number of file connections.
-			s.max(file.ConnectionType)
+			// Wait for an upload slot, see Config.MaxFileConnections.
+			select {
+			case <-ctx.Done():
+				return
+
+			case s.uploadSlots <- struct{}{}:
+			}
 
-			// Pop the first file from the upload queue.
+			// Pop the file whose turn it is from the upload queue.
 			mu.Lock()
-			que := queue[0]
-			queue = queue[1:]
+			que := queue.pop()
 			mu.Unlock()
 
 			go func(que *QueueUpload) {
+				defer func() { <-s.uploadSlots }()
+
 				token := soul.NewToken()
 
 				ul := s.log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).With().Str("file", que.Filename).Str("peer", que.Peer.username).Uint32("token", uint32(token)).Logger()
 
-				transferResponse := que.Peer.Relays.TransferResponse.Listener(0)
-				defer transferResponse.Close()
+				// The share index may have changed since the file was queued.
+				local, err := s.local(que.Filename)
+				if err != nil {
+					ul.Warn().Err(err).Msg("local path")
+
+					err = s.deny(que.Peer, que.Filename, peer.ErrFileNotShared)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload denied")
+					}
 
-				localFile, err := os.OpenFile(que.Filename, os.O_RDONLY, 0644)
+					return
+				}
+
+				localFile, err := os.OpenFile(local, os.O_RDONLY, 0644)
 				if err != nil {
 					ul.Warn().Err(err).Msg("open file")
+
+					err = s.uploadFailed(que.Peer, que.Filename)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload failed")
+					}
+
 					return
 				}
 
@@ -1251,17 +1409,48 @@ func (s *State) queue(ctx context.Context) {
 				info, err := localFile.Stat()
 				if err != nil {
 					ul.Warn().Err(err).Msg("stat file")
+
+					err = s.uploadFailed(que.Peer, que.Filename)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload failed")
+					}
+
+					return
+				}
+
+				// The P connection the file was queued on may have closed since.
+				p, err := s.open(ctx, que.Peer.username)
+				if err != nil {
+					ul.Warn().Err(err).Msg("peer connection")
+
+					// The connection the file was queued on, if it is still open.
+					err = s.uploadFailed(que.Peer, que.Filename)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload failed")
+					}
+
 					return
 				}
 
-				conn, obfuscated := que.Peer.Conn(peer.ConnectionType)
-				if conn == nil { // TODO: finish me.
+				transferResponse := p.Relays.TransferResponse.Listener(0)
+				defer closeListener(transferResponse)
+
+				conn, obfuscated := p.Conn(peer.ConnectionType)
+				if conn == nil {
+					ul.Warn().Msg("no connection")
+
+					err = s.uploadFailed(que.Peer, que.Filename)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload failed")
+					}
+
+					return
 				}
 
 				_, err = peer.Write(conn, &peer.TransferRequest{
 					Direction: peer.UploadToPeer,
 					Token:     token,
-					Filename:  info.Name(),
+					Filename:  que.Filename,
 					FileSize:  uint64(info.Size()),
 				}, obfuscated)
 				if err != nil {
@@ -1269,6 +1458,15 @@ func (s *State) queue(ctx context.Context) {
 					return
 				}
 
+				// Zero waits for as long as ctx.
+				var timeout <-chan time.Time
+				if s.client.config.UploadTimeout > 0 {
+					timer := time.NewTimer(s.client.config.UploadTimeout)
+					defer timer.Stop()
+
+					timeout = timer.C
+				}
+
 				var tResponse *peer.TransferResponse
 				for {
 					var moveOn bool
@@ -1277,6 +1475,16 @@ func (s *State) queue(ctx context.Context) {
 					case <-ctx.Done():
 						return
 
+					case <-timeout:
+						ul.Warn().Msg("transfer response timeout")
+
+						err = s.uploadFailed(p, que.Filename)
+						if err != nil {
+							ul.Warn().Err(err).Msg("upload failed")
+						}
+
+						return
+
 					case tResponse = <-transferResponse.Ch():
 						if tResponse.Token != token {
 							continue
@@ -1295,66 +1503,82 @@ func (s *State) queue(ctx context.Context) {
 					}
 				}
 
+				// Other uploads to the peer wait for their responses on the same relay.
+				closeListener(transferResponse)
+
 				s.log.Debug().Any("response", tResponse).Msg("response")
 
-				conn, err = net.Dial("tcp", fmt.Sprintf("%s:%v", que.Peer.ip.String(), que.Peer.port))
+				fileConn, err := s.fileConn(ctx, p, token)
 				if err != nil {
-					ul.Warn().Err(err).Msg("dial")
-					return
-				}
+					ul.Warn().Err(err).Msg("file connection")
 
-				_, err = peer.Write(conn, &peer.PeerInit{
-					Username:       s.client.config.Username,
-					ConnectionType: file.ConnectionType,
-				}, false)
-				if err != nil {
-					ul.Warn().Err(err).Msg("peer init")
-					return
-				}
+					err = s.uploadFailed(p, que.Filename)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload failed")
+					}
 
-				_, err = file.Write(conn, &file.TransferInit{Token: token})
-				if err != nil {
-					ul.Warn().Err(err).Msg("transfer init")
 					return
 				}
 
-				s.log.Debug().Msg("transfer init")
+				fileConn = s.bandwidth.conn(fileConn, que.Peer.username)
+				defer fileConn.Close()
 
-				offset := new(file.Offset)
-				err = offset.Deserialize(conn)
+				u, err := s.send(ctx, fileConn, localFile, info.Size())
 				if err != nil {
-					ul.Warn().Err(err).Msg("offset")
-					return
-				}
+					ul.Warn().Err(err).Msg("send file")
 
-				s.log.Debug().Any("off", offset).Msg("offset")
+					err = s.uploadFailed(p, que.Filename)
+					if err != nil {
+						ul.Warn().Err(err).Msg("upload failed")
+					}
 
-				// Send the file.
-				n, err := localFile.Seek(int64(offset.Offset), io.SeekCurrent)
-				if err != nil {
-					ul.Warn().Err(err).Msg("seek")
 					return
 				}
 
-				if n != int64(offset.Offset) {
-					ul.Warn().Int64("n", n).Uint64("offset", offset.Offset).Msg("seek not equal")
-					return
-				}
+				ul.Debug().Int64("offset", u.offset).Int64("bytes", u.bytes).Dur("duration", u.duration).
+					Int("speed", u.speed()).Msg("upload complete")
 
-				s.log.Debug().Uint64("offset", offset.Offset).Int64("file size", info.Size()).Msg("sending file")
+				s.uploaded(u.bytes, u.duration)
 
-				_, err = io.CopyN(conn, localFile, info.Size())
-				if err != nil && !errors.Is(err, io.EOF) {
-					ul.Warn().Err(err).Msg("copy")
+				// Nothing was sent when the peer had the whole file already.
+				if u.bytes == 0 {
 					return
 				}
 
-				conn.Close()
+				// The server keeps our average speed for search results and user stats.
+				_, err = server.Write(s.client.Conn(), &server.SendUploadSpeed{Speed: u.speed()})
+				if err != nil {
+					ul.Warn().Err(err).Msg("send upload speed")
+				}
 			}(que)
 		}
 	}
 }
 
+// deny tells p we will not upload filename to them.
+func (s *State) deny(p *Peer, filename string, reason error) error {
+	conn, obfuscated := p.Conn(peer.ConnectionType)
+	if conn == nil {
+		return errors.New("connection nil")
+	}
+
+	_, err := peer.Write(conn, &peer.UploadDenied{Filename: filename, Reason: reason}, obfuscated)
+
+	return err
+}
+
+// uploadFailed tells p the upload of filename broke off after it started.
+func (s *State) uploadFailed(p *Peer, filename string) error {
+	conn, obfuscated := p.Conn(peer.ConnectionType)
+	if conn == nil {
+		return errors.New("connection nil")
+	}
+
+	_, err := peer.Write(conn, &peer.UploadFailed{Filename: filename}, obfuscated)
+
+	return err
+}
+
 func (s *State) distributed(m *server.PossibleParents) {
 	for _, parent := range m.Parents {
 		pl := s.log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).With().Str("parent", parent.Username).Logger()
@@ -1577,6 +1801,9 @@ func (s *State) peerRequests(ctx context.Context, p *Peer, wg *sync.WaitGroup) {
 	piq := p.Relays.PlaceInQueueRequest.Listener(1)
 	defer piq.Close()
 
+	tr := p.Relays.TransferRequest.Listener(1)
+	defer tr.Close()
+
 	if wg != nil {
 		wg.Done()
 	}
@@ -1589,39 +1816,72 @@ func (s *State) peerRequests(ctx context.Context, p *Peer, wg *sync.WaitGroup) {
 		case fileResponse := <-fileSearch.Ch():
 			prl.Debug().Any("fileResponse", fileResponse).Msg("file search response")
 
-			go func(fileResponse *peer.FileSearchResponse) {
-				s.mu.RLock()
-				channel, ok := s.searches[fileResponse.Token]
-				s.mu.RUnlock()
+			s.mu.RLock()
+			session, ok := s.searches[fileResponse.Token]
+			s.mu.RUnlock()
 
-				switch ok {
-				case true:
-					for _, f := range fileResponse.Results {
-						channel <- &File{
-							Username: fileResponse.Username,
-							Token:    fileResponse.Token,
-							Queue:    fileResponse.Queue,
-							File:     &f,
-						}
-					}
+			if !ok {
+				s.log.Debug().Any("message", fileResponse).Msg("search not found")
+				continue
+			}
 
-					for _, f := range fileResponse.PrivateResults {
-						channel <- &File{
-							Username: fileResponse.Username,
-							Token:    fileResponse.Token,
-							Queue:    fileResponse.Queue,
-							File:     &f,
-						}
-					}
+			session.add(fileResponse)
+
+		case tr := <-tr.Ch():
+			// Uploads to us are handled by download.
+			if tr.Direction != peer.DownloadFromPeer {
+				continue
+			}
+
+			prl.Debug().Any("tr", tr).Msg("transfer request")
+
+			conn, obfuscated := p.Conn(peer.ConnectionType)
+			if conn == nil {
+				prl.Warn().Any("tr", tr).Msg("no connection")
+				continue
+			}
+
+			// Legacy clients ask for files with a transfer request instead of queueing them.
+			// We queue them all the same and tell them so.
+			reason := peer.ErrQueued
+			_, err := s.local(tr.Filename)
+			if err != nil {
+				reason = peer.ErrFileNotShared
+			}
+
+			_, err = peer.Write(conn, &peer.TransferResponse{
+				Token:   tr.Token,
+				Allowed: false,
+				Reason:  reason,
+			}, obfuscated)
+			if err != nil {
+				prl.Warn().Err(err).Any("tr", tr).Msg("transfer response")
+				continue
+			}
 
-				case false:
-					s.log.Debug().Any("message", fileResponse).Msg("search not found")
+			if reason == peer.ErrQueued {
+				s.addToQueue <- &QueueUpload{
+					Filename: tr.Filename,
+					Peer:     p,
 				}
-			}(fileResponse)
+			}
 
 		case qu := <-qu.Ch():
 			prl.Debug().Any("qu", qu).Msg("queue upload request")
 
+			// Only files in the share index can be queued.
+			_, err := s.local(qu.Filename)
+			if err != nil {
+				prl.Warn().Err(err).Msg("queue upload request")
+
+				err = s.deny(p, qu.Filename, peer.ErrFileNotShared)
+				if err != nil {
+					prl.Warn().Err(err).Msg("upload denied")
+				}
+
+				continue
+			}
+
 			s.addToQueue <- &QueueUpload{
 				Filename: qu.Filename,
 				Peer:     p,
@@ -1666,7 +1926,7 @@ func (s *State) peerRequests(ctx context.Context, p *Peer, wg *sync.WaitGroup) {
 				Picture:     s.client.config.Picture,
 				TotalUpload: uint32(s.client.config.MaxFileConnections),
 				QueueSize:   uint32(size),
-				FreeSlots:   s.client.config.MaxFileConnections > s.connectedF,
+				FreeSlots:   s.freeSlot(),
 			}, obfuscated)
 			if err != nil {
 				prl.Warn().Err(err).Msg("user info response")
@@ -1684,19 +1944,12 @@ func (s *State) peerRequests(ctx context.Context, p *Peer, wg *sync.WaitGroup) {
 				continue
 			}
 
-			var folders []peer.Directory
-			s.mu.RLock()
-			for _, directory := range s.shared.Directories {
-				if strings.Contains(directory.Name, fc.Folder) {
-					folders = append(folders, peer.Directory{
-						Name:  directory.Name,
-						Files: directory.Files,
-					})
-				}
+			folders, err := s.folder(fc.Folder)
+			if err != nil {
+				prl.Warn().Err(err).Any("fc", fc).Msg("folder contents request")
 			}
-			s.mu.RUnlock()
 
-			_, err := peer.Write(conn, &peer.FolderContentsResponse{
+			_, err = peer.Write(conn, &peer.FolderContentsResponse{
 				Token:   fc.Token,
 				Folder:  fc.Folder,
 				Folders: folders,
@@ -1804,14 +2057,6 @@ func (s *State) count(ctx context.Context, connType soul.ConnectionType, p *Peer
 			}
 			s.mu.Unlock()
 		}()
-
-	case file.ConnectionType:
-		atomic.AddInt64(&s.connectedF, 1)
-
-		go func() {
-			<-ctx.Done()
-			atomic.AddInt64(&s.connectedF, -1)
-		}()
 	}
 }
 
@@ -1819,9 +2064,7 @@ func (s *State) max(connType soul.ConnectionType) {
 	switch connType {
 	case peer.ConnectionType:
 		for {
-			s.mu.RLock()
-			ok := s.connectedP < s.client.config.MaxPeers
-			s.mu.RUnlock()
+			ok := atomic.LoadInt64(&s.connectedP) < s.client.config.MaxPeers
 
 			if ok {
 				break
@@ -1837,21 +2080,6 @@ func (s *State) max(connType soul.ConnectionType) {
 			ok := len(s.children) < s.client.config.MaxChildren
 			s.mu.RUnlock()
 
-			if ok {
-				break
-			} else {
-				time.Sleep(1 * time.Second)
-				continue
-			}
-		}
-
-	case file.ConnectionType:
-		for {
-			s.mu.RLock()
-			ok := s.connectedF < s.client.config.MaxFileConnections
-			s.log.Debug().Int64("connectedF", s.connectedF).Int64("max", s.client.config.MaxFileConnections).Msg("max")
-			s.mu.RUnlock()
-
 			if ok {
 				break
 			} else {
@@ -1887,7 +2115,14 @@ type Search struct {
 }
 
 func (s *State) request(r *Search) {
-	if r != nil {
-		s.Incoming <- r
+	if r == nil {
+		return
 	}
+
+	if s.client.config.AutoRespond {
+		s.responses <- r
+		return
+	}
+
+	s.Incoming <- r
 }
@@ -2,80 +2,772 @@ package client
 
 import (
 	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/server"
+	"github.com/bh90210/soul/soultest"
+	"github.com/bh90210/soul/testdata"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestDownload(t *testing.T) {
-	if testing.Short() {
-		return
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.ExcludedSearchPhrases = []string{"excluded"}
+
+	state := newState(ctx, t, s, "user1", "")
+
+	result, err := state.Login(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user1"}, s.Users())
+
+	assert.Equal(t, s.Greet, result.Greet)
+	assert.Equal(t, net.IPv4(127, 0, 0, 1).To4(), result.IP.To4())
+	assert.Equal(t, "5f4dcc3b5aa765d61d8327deb882cf99", result.Sum)
+	assert.Zero(t, result.PrivilegeTimeLeft)
+	assert.Equal(t, []string{"excluded"}, result.ExcludedSearchPhrases)
+	assert.Equal(t, time.Duration(s.WishlistInterval)*time.Second, result.WishlistInterval)
+	assert.Equal(t, s.ParentMinSpeed, result.ParentMinSpeed)
+	assert.Equal(t, s.ParentSpeedRatio, result.ParentSpeedRatio)
+	assert.Empty(t, result.Rooms)
+}
+
+func TestLoginInvalidPass(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	login(ctx, t, s, "user1", "")
+
+	state := newState(ctx, t, s, "user1", "")
+	state.client.config.Password = "wrong"
+
+	_, err := state.Login(ctx)
+	assert.ErrorIs(t, err, server.ErrInvalidPass)
+}
+
+func TestLoginContext(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := newState(ctx, t, s, "user1", "")
+
+	expired, cancelExpired := context.WithCancel(ctx)
+	cancelExpired()
+
+	_, err := state.Login(expired)
+	assert.ErrorIs(t, err, ErrLoginIncomplete)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	token := soul.NewToken()
+	results, err := state1.Search(ctx, "mp3", token)
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timeout")
+
+	case f := <-results:
+		assert.Equal(t, "user2", f.Username)
+		assert.Equal(t, token, f.Token)
+		assert.Equal(t, `@@`+filepath.Base(state2.client.config.Library)+`\file_example_MP3_700KB.mp3`, f.Name)
 	}
+}
 
+func TestSearchRoom(t *testing.T) {
 	t.Parallel()
 
-	// User 1 Login.
-	user1, err := New(DefaultConfig())
-	assert.NoError(t, err)
+	s := fakeServer(t)
 
-	user1.config.SoulSeekAddress = "localhost"
-	user1.config.LogLevel = zerolog.Disabled
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+	state3 := login(ctx, t, s, "user3", library(t))
+
+	go respond(ctx, state2)
+	go respond(ctx, state3)
+
+	join(t, state2, "room")
+
+	searchCtx, stop := context.WithTimeout(ctx, time.Second)
+	defer stop()
 
-	err = user1.Dial(ctx, cancel)
-	assert.NoError(t, err)
+	results, err := state1.SearchRoom(searchCtx, "room", "mp3")
+	require.NoError(t, err)
 
-	state1 := NewState(user1)
+	assert.Equal(t, []string{"user2"}, usernames(results))
+}
+
+func TestSearchUser(t *testing.T) {
+	t.Parallel()
 
-	err = state1.Login(ctx)
-	assert.NoError(t, err)
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// User 2 Login.
-	user2, err := New(DefaultConfig())
-	assert.NoError(t, err)
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+	state3 := login(ctx, t, s, "user3", library(t))
 
-	user2.config.SoulSeekAddress = "localhost"
-	user2.config.LogLevel = zerolog.Disabled
-	user2.config.OwnPort = 2236
-	user2.config.OwnPortObfuscated = 2237
-	ctx, cancel = context.WithCancel(context.Background())
+	go respond(ctx, state2)
+	go respond(ctx, state3)
 
-	err = user2.Dial(ctx, cancel)
-	assert.NoError(t, err)
+	searchCtx, stop := context.WithTimeout(ctx, time.Second)
+	defer stop()
 
-	state2 := NewState(user2)
+	results, err := state1.SearchUser(searchCtx, "user3", "mp3")
+	require.NoError(t, err)
 
-	err = state2.Login(ctx)
-	assert.NoError(t, err)
+	assert.Equal(t, []string{"user3"}, usernames(results))
+}
+
+func TestDownload(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
 
-	// User 1 Search.
 	token := soul.NewToken()
-	results, err := state1.Search(ctx, "test", token)
-	assert.NoError(t, err)
+	results, err := state1.Search(ctx, "mp3", token)
+	require.NoError(t, err)
+
+	var f *File
+	select {
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "search timeout")
+
+	case f = <-results:
+	}
 
-	deadline := time.NewTimer(5 * time.Second)
+	transfer, err := state1.Download(ctx, f)
+	require.NoError(t, err)
+
+	deadline := time.NewTimer(10 * time.Second)
 	defer deadline.Stop()
 
+	var states []TransferState
 	for {
 		select {
 		case <-deadline.C:
-			assert.Fail(t, "timeout")
+			require.FailNow(t, "download timeout")
+
+		case e, ok := <-transfer.Events:
+			require.True(t, ok, "events closed before the transfer ended")
+
+			// Whether our place in the queue arrives before the transfer starts is up to timing.
+			if e.State == TransferQueued {
+				continue
+			}
+
+			if len(states) == 0 || states[len(states)-1] != e.State {
+				states = append(states, e.State)
+			}
+
+			if !e.State.Final() {
+				continue
+			}
+
+			require.NoError(t, e.Err)
+			assert.Equal(t, []TransferState{TransferRequested, TransferInitializing, TransferInProgress, TransferCompleted}, states)
+			assert.Equal(t, int64(f.Size), e.Bytes)
+			assert.Equal(t, int64(f.Size), e.Total)
+
+			_, ok = <-transfer.Events
+			assert.False(t, ok)
+
+			expected, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+			require.NoError(t, err)
+
+			// The file is saved in the folder it is shared from and nothing is left behind.
+			assert.Equal(t, filepath.Join(state1.client.config.DownloadFolder, filepath.Base(state2.client.config.Library), "file_example_MP3_700KB.mp3"), e.Path)
+
+			downloaded, err := os.ReadFile(e.Path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, downloaded)
+
+			_, err = os.Stat(incompletePath(state1.client.config, f))
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+
+			// The uploader reported its speed to the server.
+			assert.Eventually(t, func() bool {
+				return stats(t, state2).Speed > 0
+			}, 5*time.Second, 50*time.Millisecond)
 			return
+		}
+	}
+}
 
-		case f := <-results:
-			status, err := state1.Download(ctx, f)
-			for {
-				select {
-				case <-status:
-					return
+func TestDownloadByName(t *testing.T) {
+	t.Parallel()
 
-				case <-err:
-					return
-				}
+	// Users that cannot be connected to directly: the uploader has to connect to us for
+	// the P connection, the downloader for the F connection.
+	tests := map[string]string{
+		"direct":                "",
+		"uploader firewalled":   "user2",
+		"downloader firewalled": "user1",
+	}
+
+	for name, firewalled := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := fakeServer(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			state1 := login(ctx, t, s, "user1", "")
+			state1.client.config.DownloadFolder = t.TempDir()
+
+			state2 := login(ctx, t, s, "user2", library(t))
+
+			if firewalled != "" {
+				require.True(t, s.Firewall(firewalled))
 			}
+
+			expected, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+			require.NoError(t, err)
+
+			// Nothing but a username and a path, no search brought us in touch with user2.
+			transfer, err := state1.Download(ctx, &File{
+				Username: "user2",
+				File: &peer.File{
+					Name: `@@` + filepath.Base(state2.client.config.Library) + `\file_example_MP3_700KB.mp3`,
+					Size: uint64(len(expected)),
+				},
+			})
+			require.NoError(t, err)
+
+			e := final(t, transfer)
+			require.Equal(t, TransferCompleted, e.State, e.Err)
+
+			downloaded, err := os.ReadFile(e.Path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, downloaded)
+		})
+	}
+}
+
+func TestDownloadOversized(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	expected, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+	require.NoError(t, err)
+
+	f := &File{
+		Username: "user2",
+		File: &peer.File{
+			Name: `@@` + filepath.Base(state2.client.config.Library) + `\file_example_MP3_700KB.mp3`,
+			Size: uint64(len(expected)),
+		},
+	}
+
+	// An incomplete file larger than the file itself, ie. of another version of it.
+	incomplete := incompletePath(state1.client.config, f)
+	require.NoError(t, os.MkdirAll(filepath.Dir(incomplete), 0755))
+	require.NoError(t, os.WriteFile(incomplete, make([]byte, len(expected)+100), 0644))
+
+	transfer, err := state1.Download(ctx, f)
+	require.NoError(t, err)
+
+	e := final(t, transfer)
+	require.Equal(t, TransferCompleted, e.State, e.Err)
+
+	downloaded, err := os.ReadFile(e.Path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, downloaded)
+}
+
+func TestDownloadNoPeer(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := login(ctx, t, s, "user1", "")
+
+	transfer, err := state.Download(ctx, &File{Username: "nobody", File: &peer.File{Name: `@@music\track.flac`, Size: 1}})
+	require.NoError(t, err)
+
+	e := final(t, transfer)
+	assert.Equal(t, TransferFailed, e.State)
+	assert.ErrorIs(t, e.Err, ErrNoConnection)
+
+	_, err = state.Download(ctx, &File{File: &peer.File{Name: "track.flac"}})
+	assert.ErrorIs(t, err, ErrNoUsername)
+}
+
+func TestDownloadNotShared(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	results, err := state1.Search(ctx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	var f *File
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "search timeout")
+
+	case f = <-results:
+	}
+
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	err = os.WriteFile(secret, []byte("secret"), 0644)
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		secret,
+		virtualDir(f.Name) + `\..\..\..\` + strings.ReplaceAll(strings.TrimPrefix(secret, "/"), "/", `\`),
+		`@@unknown\secret.txt`,
+	} {
+		transfer, err := state1.Download(ctx, &File{
+			Username: f.Username,
+			Token:    soul.NewToken(),
+			File:     &peer.File{Name: name, Size: 6},
+		})
+		require.NoError(t, err)
+
+		e := final(t, transfer)
+		assert.Equal(t, TransferFailed, e.State, name)
+		assert.ErrorIs(t, e.Err, peer.ErrFileNotShared, name)
+	}
+}
+
+func TestDownloadUploadFailed(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state1.client.config.DownloadFolder = t.TempDir()
+
+	shared := library(t)
+	state2 := login(ctx, t, s, "user2", shared)
+
+	go respond(ctx, state2)
+
+	results, err := state1.Search(ctx, "mp3", soul.NewToken())
+	require.NoError(t, err)
+
+	var f *File
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "search timeout")
+
+	case f = <-results:
+	}
+
+	// The file is still in the share index, but gone from disk.
+	require.NoError(t, os.Remove(filepath.Join(shared, "file_example_MP3_700KB.mp3")))
+
+	transfer, err := state1.Download(ctx, f)
+	require.NoError(t, err)
+
+	e := final(t, transfer)
+	assert.Equal(t, TransferFailed, e.State)
+	assert.ErrorIs(t, e.Err, peer.ErrFileReadError)
+}
+
+func TestReconnect(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	// incoming returns the next search state2 is asked to answer.
+	incoming := func() *Search {
+		select {
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "search timeout")
+			return nil
+
+		case r := <-state2.Incoming:
+			return r
 		}
 	}
+
+	token := soul.NewToken()
+	_, err := state1.Search(ctx, "mp3", token)
+	require.NoError(t, err)
+
+	assert.Equal(t, token, incoming().Token)
+
+	require.True(t, s.Disconnect("user1"))
+
+	assert.Equal(t, SessionDisconnected, session(t, state1).Status)
+	assert.Equal(t, SessionReconnected, session(t, state1).Status)
+	assert.Equal(t, []string{"user1", "user2"}, s.Users())
+
+	// The search is sent again after the reconnect. Results the session already has are
+	// not streamed again.
+	r := incoming()
+	assert.Equal(t, token, r.Token)
+	assert.Equal(t, "user1", r.Username)
+}
+
+func TestReconnectFailedLogin(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state := login(ctx, t, s, "user1", "")
+
+	// The first reconnect is turned away, the server closing that connection too.
+	require.True(t, s.RejectLogins("user1", 1))
+	require.True(t, s.Disconnect("user1"))
+
+	assert.Equal(t, SessionDisconnected, session(t, state).Status)
+
+	e := session(t, state)
+	assert.Equal(t, SessionReconnecting, e.Status)
+	assert.ErrorIs(t, e.Err, server.ErrInvalidPass)
+
+	assert.Equal(t, SessionReconnected, session(t, state).Status)
+
+	// The drop of the rejected connection does not take the new session down.
+	select {
+	case <-time.After(time.Second):
+
+	case e := <-state.Session:
+		assert.Fail(t, "session event after reconnect", "%s: %v", e.Status, e.Err)
+	}
+
+	assert.Equal(t, []string{"user1"}, s.Users())
+}
+
+func TestRelogged(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	login(ctx, t, s, "user1", "")
+
+	e := session(t, state1)
+	assert.Equal(t, SessionRelogged, e.Status)
+	assert.ErrorIs(t, e.Err, ErrRelogged)
+
+	// A kick is deliberate, the first session must not take the username back.
+	select {
+	case e := <-state1.Session:
+		assert.Fail(t, "unexpected session event", e.Status)
+
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	assert.Equal(t, []string{"user1"}, s.Users())
+}
+
+func TestRescan(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := library(t)
+	state := login(ctx, t, s, "user1", dir)
+
+	assert.Equal(t, 1, stats(t, state).Files)
+	assert.FileExists(t, filepath.Join(state.client.config.StateFolder, indexCache))
+
+	err := os.WriteFile(filepath.Join(dir, "added.txt"), []byte("added"), 0644)
+	require.NoError(t, err)
+
+	err = state.Rescan(ctx)
+	require.NoError(t, err)
+
+	m := stats(t, state)
+	assert.Equal(t, 2, m.Files)
+	assert.Equal(t, 1, m.Directories)
+
+	cached, err := loadIndex(state.client.config.StateFolder, state.shares)
+	require.NoError(t, err)
+	assert.Contains(t, cached, filepath.Join(dir, "added.txt"))
+}
+
+// stats asks the server for the stats of s's own user.
+func stats(t *testing.T, s *State) *server.GetUserStats {
+	t.Helper()
+
+	lis := s.client.Relays.GetUserStats.Listener(1)
+	defer lis.Close()
+
+	_, err := server.Write(s.client.Conn(), &server.GetUserStats{Username: s.client.config.Username})
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "user stats timeout")
+		return nil
+
+	case m := <-lis.Ch():
+		return m
+	}
+}
+
+// join makes s join room and waits for the server to confirm it.
+func join(t *testing.T, s *State, room string) {
+	t.Helper()
+
+	lis := s.client.Relays.JoinRoom.Listener(1)
+	defer lis.Close()
+
+	_, err := server.Write(s.client.Conn(), &server.JoinRoom{Room: room})
+	require.NoError(t, err)
+
+	select {
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "join room timeout")
+
+	case <-lis.Ch():
+	}
+}
+
+// usernames returns the users who sent the results, in the order they first did, once
+// results is closed.
+func usernames(results chan *File) []string {
+	var usernames []string
+	for f := range results {
+		if !slices.Contains(usernames, f.Username) {
+			usernames = append(usernames, f.Username)
+		}
+	}
+
+	return usernames
+}
+
+// session returns the next session event of s.
+func session(t *testing.T, s *State) *SessionEvent {
+	t.Helper()
+
+	select {
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "session event timeout")
+		return nil
+
+	case e := <-s.Session:
+		return e
+	}
+}
+
+// final returns the last event of transfer.
+func final(t *testing.T, transfer *Transfer) *TransferEvent {
+	t.Helper()
+
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case <-deadline:
+			require.FailNow(t, "transfer timeout")
+			return nil
+
+		case e := <-transfer.Events:
+			if e.State.Final() {
+				return e
+			}
+		}
+	}
+}
+
+// fakeServer starts an in-process server that is closed at the end of the test.
+func fakeServer(t *testing.T) *soultest.Server {
+	t.Helper()
+
+	s, err := soultest.NewServer()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// login connects a new user to the server and logs in. The library is the folder the
+// user shares, it can be empty.
+func login(ctx context.Context, t *testing.T, s *soultest.Server, username, library string) *State {
+	t.Helper()
+
+	state := newState(ctx, t, s, username, library)
+
+	_, err := state.Login(ctx)
+	require.NoError(t, err)
+
+	return state
+}
+
+// newState connects a new user to the server without logging in.
+func newState(ctx context.Context, t *testing.T, s *soultest.Server, username, library string) *State {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.SoulSeekAddress = s.Host()
+	config.SoulSeekPort = s.Port()
+	config.OwnHostname = "127.0.0.1"
+	config.OwnPort = freePort(t)
+	config.OwnPortObfuscated = freePort(t)
+	config.Username = username
+	config.Password = "password"
+	config.LogLevel = zerolog.Disabled
+	config.LoginTimeout = 5 * time.Second
+	config.ReconnectBackoff = 10 * time.Millisecond
+	config.Library = library
+	config.StateFolder = t.TempDir()
+	if library == "" {
+		config.Library = t.TempDir()
+	}
+
+	c, err := New(config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	err = c.Dial(ctx, cancel)
+	require.NoError(t, err)
+
+	return NewState(c)
+}
+
+// library returns a folder sharing the test mp3 file.
+func library(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	content, err := testdata.Testdata.ReadFile("file_example_MP3_700KB.mp3")
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "file_example_MP3_700KB.mp3"), content, 0644)
+	require.NoError(t, err)
+
+	return dir
+}
+
+// respond answers every incoming search with the whole shared library.
+func respond(ctx context.Context, s *State) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case request := <-s.Incoming:
+			var files []*File
+			s.mu.RLock()
+			for _, e := range s.indexed {
+				files = append(files, &File{
+					Username: request.Username,
+					Token:    request.Token,
+					File:     &e.File,
+				})
+			}
+			s.mu.RUnlock()
+
+			go func() {
+				err := s.Respond(ctx, files)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					s.log.Warn().Err(err).Msg("respond")
+				}
+			}()
+		}
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
 }
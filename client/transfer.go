@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+)
+
+// transferEvents is the buffer size of Transfer.Events. Progress events are dropped if
+// the buffer is full, every other event waits to be read.
+const transferEvents = 16
+
+// TransferState represents a state in the life of a file transfer.
+type TransferState string
+
+const (
+	// TransferRequested indicates that we asked the peer for the file and wait for an answer.
+	TransferRequested TransferState = "requested"
+	// TransferQueued indicates that the peer queued the file, TransferEvent.Position
+	// is our place in its queue when the peer told us.
+	TransferQueued TransferState = "queued"
+	// TransferInitializing indicates that the peer is ready to send the file and the
+	// file connection is being set up.
+	TransferInitializing TransferState = "initializing"
+	// TransferInProgress indicates that file data is flowing, see TransferEvent.Bytes.
+	TransferInProgress TransferState = "in progress"
+	// TransferCompleted indicates that the whole file is on disk.
+	TransferCompleted TransferState = "completed"
+	// TransferFailed indicates that the transfer stopped, TransferEvent.Err says why.
+	TransferFailed TransferState = "failed"
+	// TransferCancelled indicates that the transfer was cancelled, either with
+	// Transfer.Cancel or by its context.
+	TransferCancelled TransferState = "cancelled"
+)
+
+// Final reports whether no other state follows s.
+func (s TransferState) Final() bool {
+	return s == TransferCompleted || s == TransferFailed || s == TransferCancelled
+}
+
+// TransferEvent is sent to Transfer.Events whenever the transfer changes state or
+// makes progress.
+type TransferEvent struct {
+	State TransferState
+	// Position is our place in the peer's upload queue, set for TransferQueued.
+	Position int
+	// Bytes is how much of the file is on disk so far and Total the size of the file.
+	Bytes int64
+	Total int64
+	// Rate is the transfer speed in bytes per second, set for TransferInProgress.
+	Rate int64
+	// Path is where the file was saved, set for TransferCompleted.
+	Path string
+	// Source is the user the file is downloaded from, File.Username or one of
+	// File.Sources.
+	Source string
+	// Err is the reason of TransferFailed and TransferCancelled. Reasons given by the
+	// peer are the matching peer.Err* sentinels, cancellations wrap peer.ErrCancelled.
+	Err error
+}
+
+// Transfer is a file download started with State.Download.
+type Transfer struct {
+	// File is the file being downloaded.
+	File *File
+	// Events reports the progress of the transfer. It is closed after the final
+	// TransferCompleted, TransferFailed or TransferCancelled event.
+	Events chan *TransferEvent
+
+	cancel context.CancelCauseFunc
+	mu     sync.Mutex
+	last   *TransferEvent
+	// sending orders the sends to Events, so that a slow reader does not hold mu.
+	sending sync.Mutex
+	closed  bool
+	start   time.Time
+	// from is the user the file is downloaded from.
+	from string
+}
+
+// newTransfer returns a new Transfer of f and the context the transfer runs with.
+func newTransfer(ctx context.Context, f *File) (*Transfer, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	return &Transfer{
+		File:   f,
+		Events: make(chan *TransferEvent, transferEvents),
+		cancel: cancel,
+		last:   &TransferEvent{State: TransferRequested, Total: int64(f.Size), Source: f.Username},
+		from:   f.Username,
+	}, ctx
+}
+
+// Cancel stops the transfer. What was downloaded so far stays on disk so that a later
+// download of the same file resumes from there.
+func (t *Transfer) Cancel() {
+	t.cancel(peer.ErrCancelled)
+}
+
+// State returns the latest event of the transfer.
+func (t *Transfer) State() TransferEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return *t.last
+}
+
+// event records e and sends it to Events. Nothing is sent once the transfer is over.
+func (t *Transfer) event(e *TransferEvent) {
+	t.mu.Lock()
+	if t.last.State.Final() {
+		t.mu.Unlock()
+		return
+	}
+
+	if e.Total == 0 {
+		e.Total = int64(t.File.Size)
+	}
+
+	if e.Source == "" {
+		e.Source = t.from
+	}
+
+	t.last = e
+	t.mu.Unlock()
+
+	t.sending.Lock()
+	defer t.sending.Unlock()
+
+	// A final event recorded after e was already sent.
+	if t.closed {
+		return
+	}
+
+	if e.State.Final() {
+		t.Events <- e
+		close(t.Events)
+		t.closed = true
+		return
+	}
+
+	if e.State == TransferInProgress {
+		select {
+		case t.Events <- e:
+		default:
+		}
+
+		return
+	}
+
+	t.Events <- e
+}
+
+// queued reports our place in the peer's queue.
+func (t *Transfer) queued(position int) {
+	t.event(&TransferEvent{State: TransferQueued, Position: position})
+}
+
+// source reports that the transfer starts over from username, with what is on disk.
+func (t *Transfer) source(username string) {
+	t.mu.Lock()
+	t.from = username
+	t.start = time.Time{}
+	t.mu.Unlock()
+
+	t.event(&TransferEvent{State: TransferRequested})
+}
+
+// progress reports that bytes of the file are on disk, of which read were received
+// since the transfer started.
+func (t *Transfer) progress(bytes, read int64) {
+	t.mu.Lock()
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	var rate int64
+	if elapsed := time.Since(t.start).Seconds(); elapsed > 0 {
+		rate = int64(float64(read) / elapsed)
+	}
+	t.mu.Unlock()
+
+	t.event(&TransferEvent{State: TransferInProgress, Bytes: bytes, Rate: rate})
+}
+
+// fail ends the transfer with reason.
+func (t *Transfer) fail(reason error) {
+	t.event(&TransferEvent{State: TransferFailed, Err: reason})
+}
+
+// done ends the transfer when its context is done, telling a cancellation from a
+// failure that cancelled the context first.
+func (t *Transfer) done(ctx context.Context) {
+	cause := context.Cause(ctx)
+	if cause != peer.ErrCancelled {
+		cause = fmt.Errorf("%w: %w", peer.ErrCancelled, cause)
+	}
+
+	t.event(&TransferEvent{State: TransferCancelled, Err: cause})
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferEvents(t *testing.T) {
+	t.Parallel()
+
+	transfer, _ := newTransfer(context.Background(), &File{File: &peer.File{Name: "file", Size: 100}})
+	assert.Equal(t, TransferRequested, transfer.State().State)
+
+	transfer.queued(3)
+	transfer.progress(10, 0)
+	transfer.fail(peer.ErrFileReadError)
+
+	// Nothing follows a final state.
+	transfer.event(&TransferEvent{State: TransferCompleted})
+
+	var events []TransferEvent
+	for e := range transfer.Events {
+		events = append(events, *e)
+	}
+
+	require.Len(t, events, 3)
+	assert.Equal(t, TransferEvent{State: TransferQueued, Position: 3, Total: 100}, events[0])
+	assert.Equal(t, TransferInProgress, events[1].State)
+	assert.Equal(t, int64(10), events[1].Bytes)
+	assert.Equal(t, TransferEvent{State: TransferFailed, Total: 100, Err: peer.ErrFileReadError}, events[2])
+	assert.Equal(t, TransferFailed, transfer.State().State)
+}
+
+func TestTransferCancel(t *testing.T) {
+	t.Parallel()
+
+	transfer, ctx := newTransfer(context.Background(), &File{File: &peer.File{Name: "file", Size: 100}})
+	transfer.Cancel()
+
+	<-ctx.Done()
+	transfer.done(ctx)
+
+	e := <-transfer.Events
+	assert.Equal(t, TransferCancelled, e.State)
+	assert.Equal(t, peer.ErrCancelled, e.Err)
+
+	parent, cancel := context.WithCancel(context.Background())
+	transfer, ctx = newTransfer(parent, &File{File: &peer.File{Name: "file", Size: 100}})
+	cancel()
+
+	<-ctx.Done()
+	transfer.done(ctx)
+
+	e = <-transfer.Events
+	assert.Equal(t, TransferCancelled, e.State)
+	assert.ErrorIs(t, e.Err, peer.ErrCancelled)
+	assert.ErrorIs(t, e.Err, context.Canceled)
+}
+
+func TestTransferSlowReader(t *testing.T) {
+	t.Parallel()
+
+	transfer, _ := newTransfer(context.Background(), &File{File: &peer.File{Name: "file", Size: 100}})
+
+	// Fill Events, the next event waits for a reader.
+	for i := range transferEvents {
+		transfer.queued(i + 1)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		transfer.queued(transferEvents + 1)
+		close(sent)
+	}()
+
+	// The waiting event is recorded, and State does not wait with it.
+	require.Eventually(t, func() bool {
+		return transfer.State().Position == transferEvents+1
+	}, time.Second, time.Millisecond)
+
+	<-transfer.Events
+	<-sent
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/bh90210/soul/file"
+	"github.com/bh90210/soul/peer"
+)
+
+// megabyte is the unit of Config.MaxQueuedMegabytesPerUser.
+const megabyte = 1 << 20
+
+// uploadQueue orders the uploads waiting for a slot. Privileged users go first, then
+// everyone else, and in each lane users take turns one file at a time, so that a user
+// queueing many files does not hold up the others.
+type uploadQueue struct {
+	files map[string][]*queuedUpload
+	// turns are the users with queued files, in the order they take their turn.
+	turns      []string
+	privileged func(username string) bool
+}
+
+// queuedUpload is a queued file and its size.
+type queuedUpload struct {
+	*QueueUpload
+	size int64
+}
+
+// newUploadQueue returns an empty queue that puts the users privileged reports first.
+func newUploadQueue(privileged func(username string) bool) *uploadQueue {
+	return &uploadQueue{
+		files:      make(map[string][]*queuedUpload),
+		privileged: privileged,
+	}
+}
+
+// add queues que, a file of size bytes, unless it is queued already. Users who are not
+// privileged may queue up to maxFiles files and maxMegabytes megabytes, zero does not
+// cap, adding more returns peer.ErrTooManyFiles or peer.ErrTooManyMegabytes.
+func (q *uploadQueue) add(que *QueueUpload, size int64, maxFiles, maxMegabytes int) error {
+	username := que.Peer.username
+
+	files := q.files[username]
+	for _, f := range files {
+		if f.Filename == que.Filename {
+			return nil
+		}
+	}
+
+	if !q.privileged(username) {
+		if maxFiles > 0 && len(files) >= maxFiles {
+			return peer.ErrTooManyFiles
+		}
+
+		total := size
+		for _, f := range files {
+			total += f.size
+		}
+
+		if maxMegabytes > 0 && total > int64(maxMegabytes)*megabyte {
+			return peer.ErrTooManyMegabytes
+		}
+	}
+
+	if len(files) == 0 {
+		q.turns = append(q.turns, username)
+	}
+
+	q.files[username] = append(files, &queuedUpload{QueueUpload: que, size: size})
+
+	return nil
+}
+
+// order returns the queued files in the order they are uploaded, unless more are added.
+func (q *uploadQueue) order() []*QueueUpload {
+	var privileged, others []string
+	for _, username := range q.turns {
+		if q.privileged(username) {
+			privileged = append(privileged, username)
+		} else {
+			others = append(others, username)
+		}
+	}
+
+	order := make([]*QueueUpload, 0, q.len())
+	for _, lane := range [][]string{privileged, others} {
+		for round := 0; ; round++ {
+			var more bool
+			for _, username := range lane {
+				if files := q.files[username]; round < len(files) {
+					order = append(order, files[round].QueueUpload)
+					more = true
+				}
+			}
+
+			if !more {
+				break
+			}
+		}
+	}
+
+	return order
+}
+
+// position returns the place of filename from username in the queue, counting from
+// one, zero if it is not queued.
+func (q *uploadQueue) position(username, filename string) int {
+	for i, que := range q.order() {
+		if que.Peer.username == username && que.Filename == filename {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// pop removes the file whose turn it is from the queue. Its user waits for everyone
+// else in its lane before the next of its files.
+func (q *uploadQueue) pop() *QueueUpload {
+	order := q.order()
+	if len(order) == 0 {
+		return nil
+	}
+
+	que := order[0]
+	username := que.Peer.username
+
+	q.files[username] = q.files[username][1:]
+	q.turns = slices.DeleteFunc(q.turns, func(u string) bool { return u == username })
+
+	if len(q.files[username]) == 0 {
+		delete(q.files, username)
+	} else {
+		q.turns = append(q.turns, username)
+	}
+
+	return que
+}
+
+// len returns the number of queued files.
+func (q *uploadQueue) len() int {
+	var n int
+	for _, files := range q.files {
+		n += len(files)
+	}
+
+	return n
+}
+
+// ErrOffset is returned when a downloader asks to resume past the end of the file.
+var ErrOffset = errors.New("offset past end of file")
+
+// upload is a finished upload session.
+type upload struct {
+	// offset is where the downloader resumed from, bytes how much we sent from there.
+	offset   int64
+	bytes    int64
+	duration time.Duration
+}
+
+// speed returns the average speed of the upload in bytes per second.
+func (u *upload) speed() int {
+	if u.duration <= 0 {
+		return 0
+	}
+
+	return int(float64(u.bytes) / u.duration.Seconds())
+}
+
+// send uploads f, of size bytes, over the F connection conn once the downloader told us
+// the offset it resumes from, within Config.UploadTimeout. A file that ends before size
+// is an io.ErrUnexpectedEOF.
+func (s *State) send(ctx context.Context, conn net.Conn, f *os.File, size int64) (*upload, error) {
+	// Closing the connection unblocks it once ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if s.client.config.UploadTimeout > 0 {
+		err := conn.SetReadDeadline(time.Now().Add(s.client.config.UploadTimeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	offset := new(file.Offset)
+	err := offset.Deserialize(conn)
+	if err != nil {
+		return nil, fmt.Errorf("offset: %w", err)
+	}
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	u := &upload{offset: int64(offset.Offset)}
+	if u.offset > size {
+		return nil, fmt.Errorf("%w: %d of %d bytes", ErrOffset, u.offset, size)
+	}
+
+	_, err = f.Seek(u.offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+
+	u.bytes, err = io.CopyN(conn, f, size-u.offset)
+	u.duration = time.Since(start)
+
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: sent %d of %d bytes", io.ErrUnexpectedEOF, u.bytes, size-u.offset)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// freeSlot reports whether an upload would start right away, see Config.MaxFileConnections.
+func (s *State) freeSlot() bool {
+	return len(s.uploadSlots) < cap(s.uploadSlots)
+}
+
+// privileged reports whether the server told us username has privileges.
+func (s *State) privileged(username string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.privilegedUsers[username] {
+		return true
+	}
+
+	p, found := s.peers[username]
+
+	return found && p.privileged
+}
+
+// setPrivileged replaces the users the server told us have privileges.
+func (s *State) setPrivileged(usernames []string) {
+	privileged := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		privileged[username] = true
+	}
+
+	s.mu.Lock()
+	s.privilegedUsers = privileged
+	s.mu.Unlock()
+}
+
+// size returns the size of the shared file at local, zero if it is not indexed.
+func (s *State) size(local string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.indexed[local].Size
+}
@@ -0,0 +1,357 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul/file"
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadQueue(t *testing.T) {
+	t.Parallel()
+
+	peers := map[string]*Peer{}
+	for _, username := range []string{"user1", "user2", "user3", "vip"} {
+		peers[username] = &Peer{username: username}
+	}
+
+	q := newUploadQueue(func(username string) bool { return username == "vip" })
+
+	for _, f := range []struct{ username, filename string }{
+		{"user1", "a"}, {"user1", "b"}, {"user1", "c"}, {"user2", "d"}, {"user1", "a"},
+		{"user3", "e"}, {"user3", "f"}, {"vip", "g"},
+	} {
+		require.NoError(t, q.add(&QueueUpload{Filename: f.filename, Peer: peers[f.username]}, 1, 0, 0))
+	}
+
+	names := func(order []*QueueUpload) []string {
+		var names []string
+		for _, que := range order {
+			names = append(names, que.Filename)
+		}
+
+		return names
+	}
+
+	// The privileged user goes first, then users take turns.
+	assert.Equal(t, []string{"g", "a", "d", "e", "b", "f", "c"}, names(q.order()))
+	assert.Equal(t, 7, q.len())
+	assert.Equal(t, 1, q.position("vip", "g"))
+	assert.Equal(t, 5, q.position("user1", "b"))
+	assert.Zero(t, q.position("user2", "a"))
+
+	// Positions are the order files are popped in.
+	assert.Equal(t, "g", q.pop().Filename)
+	assert.Equal(t, "a", q.pop().Filename)
+	assert.Equal(t, []string{"d", "e", "b", "f", "c"}, names(q.order()))
+
+	// A user who joins later takes the next turn of the round.
+	require.NoError(t, q.add(&QueueUpload{Filename: "h", Peer: peers["vip"]}, 1, 0, 0))
+	assert.Equal(t, []string{"h", "d", "e", "b", "f", "c"}, names(q.order()))
+
+	for range 6 {
+		q.pop()
+	}
+
+	assert.Nil(t, q.pop())
+	assert.Zero(t, q.len())
+	assert.Empty(t, q.turns)
+}
+
+func TestUploadQueueCaps(t *testing.T) {
+	t.Parallel()
+
+	q := newUploadQueue(func(username string) bool { return username == "vip" })
+
+	user := &Peer{username: "user"}
+	vip := &Peer{username: "vip"}
+
+	require.NoError(t, q.add(&QueueUpload{Filename: "a", Peer: user}, megabyte, 2, 3))
+	require.NoError(t, q.add(&QueueUpload{Filename: "b", Peer: user}, megabyte, 2, 3))
+	assert.ErrorIs(t, q.add(&QueueUpload{Filename: "c", Peer: user}, 1, 2, 3), peer.ErrTooManyFiles)
+
+	// Queueing a file again is not counted.
+	assert.NoError(t, q.add(&QueueUpload{Filename: "a", Peer: user}, megabyte, 2, 3))
+
+	assert.ErrorIs(t, q.add(&QueueUpload{Filename: "c", Peer: user}, 2*megabyte, 3, 3), peer.ErrTooManyMegabytes)
+	assert.NoError(t, q.add(&QueueUpload{Filename: "c", Peer: user}, megabyte, 3, 3))
+
+	// Privileged users are not capped.
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, q.add(&QueueUpload{Filename: name, Peer: vip}, 10*megabyte, 1, 1))
+	}
+
+	assert.Equal(t, 6, q.len())
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("0123456789")
+
+	local := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(local, content, 0644))
+
+	tests := map[string]struct {
+		offset uint64
+		size   int64
+		err    error
+	}{
+		"whole file":  {offset: 0, size: 10},
+		"resume":      {offset: 4, size: 10},
+		"complete":    {offset: 10, size: 10},
+		"past end":    {offset: 11, size: 10, err: ErrOffset},
+		"file shrunk": {offset: 2, size: 12, err: io.ErrUnexpectedEOF},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := os.Open(local)
+			require.NoError(t, err)
+			defer f.Close()
+
+			uploader, downloader := net.Pipe()
+			defer downloader.Close()
+
+			received := make(chan []byte, 1)
+			go func() {
+				_, err := file.Write(downloader, &file.Offset{Offset: tt.offset})
+				if err != nil {
+					received <- nil
+					return
+				}
+
+				data, _ := io.ReadAll(downloader)
+				received <- data
+			}()
+
+			state := &State{client: &Client{config: DefaultConfig()}}
+
+			u, err := state.send(context.Background(), uploader, f, tt.size)
+			uploader.Close()
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				<-received
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, content[tt.offset:], <-received)
+			assert.Equal(t, int64(tt.offset), u.offset)
+			assert.Equal(t, tt.size-int64(tt.offset), u.bytes)
+		})
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	t.Parallel()
+
+	local := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(local, []byte("0123456789"), 0644))
+
+	f, err := os.Open(local)
+	require.NoError(t, err)
+	defer f.Close()
+
+	uploader, downloader := net.Pipe()
+	defer uploader.Close()
+	defer downloader.Close()
+
+	config := DefaultConfig()
+	config.UploadTimeout = 100 * time.Millisecond
+
+	// The downloader never tells the offset.
+	_, err = (&State{client: &Client{config: config}}).send(context.Background(), uploader, f, 10)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestUploadTimeout(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shared := library(t)
+	uploader := newState(ctx, t, s, "uploader", shared)
+	uploader.client.config.UploadTimeout = 100 * time.Millisecond
+	uploader.uploadSlots = make(chan struct{}, 1)
+
+	_, err := uploader.Login(ctx)
+	require.NoError(t, err)
+
+	// user2 queues a file but never answers the TransferRequest.
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	p := NewPeer(uploader.client.config, &peer.PeerInit{Username: "user2", ConnectionType: peer.ConnectionType})
+	uploader.mu.Lock()
+	uploader.peers["user2"] = p
+	uploader.mu.Unlock()
+
+	uploader.initializers(ctx, peer.ConnectionType, p, local, false, uploader.log)
+
+	filename := `@@` + filepath.Base(shared) + `\file_example_MP3_700KB.mp3`
+	_, err = peer.Write(remote, &peer.QueueUpload{Filename: filename}, false)
+	require.NoError(t, err)
+
+	failed := make(chan *peer.UploadFailed, 1)
+	go func() {
+		for {
+			r, _, code, err := peer.Read(peer.Code(0), remote, false)
+			if err != nil {
+				return
+			}
+
+			if code == peer.CodeUploadFailed {
+				m := new(peer.UploadFailed)
+				if assert.NoError(t, m.Deserialize(r)) {
+					failed <- m
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "upload failed timeout")
+
+	case m := <-failed:
+		assert.Equal(t, filename, m.Filename)
+	}
+
+	// The slot is free for the next upload.
+	assert.Eventually(t, uploader.freeSlot, time.Second, 10*time.Millisecond)
+}
+
+func TestUploadSlots(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	library := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, os.WriteFile(filepath.Join(library, name+".bin"), content, 0644))
+	}
+
+	uploader := newState(ctx, t, s, "uploader", library)
+	uploader.uploadSlots = make(chan struct{}, 1)
+
+	_, err := uploader.Login(ctx)
+	require.NoError(t, err)
+
+	// Every upload after the first takes a second.
+	uploader.SetBandwidth(Bandwidth{Limits: Limits{Upload: int64(len(content))}})
+
+	// Hold the only slot until everything is queued.
+	uploader.uploadSlots <- struct{}{}
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", "")
+
+	type event struct {
+		name string
+		*TransferEvent
+	}
+
+	events := make(chan event, 1000)
+	download := func(state *State, name string) {
+		state.client.config.DownloadFolder = t.TempDir()
+		state.client.config.PlaceInQueueInterval = 100 * time.Millisecond
+
+		transfer, err := state.Download(ctx, &File{
+			Username: "uploader",
+			File: &peer.File{
+				Name: `@@` + filepath.Base(library) + `\` + name + `.bin`,
+				Size: uint64(len(content)),
+			},
+		})
+		require.NoError(t, err)
+
+		go func() {
+			for e := range transfer.Events {
+				events <- event{name, e}
+			}
+		}()
+	}
+
+	next := func(match func(event) bool) event {
+		for {
+			select {
+			case <-time.After(10 * time.Second):
+				require.FailNow(t, "transfer event timeout")
+
+			case e := <-events:
+				require.NotEqual(t, TransferInitializing, e.State, "%s started with the slot taken", e.name)
+
+				if match(e) {
+					return e
+				}
+			}
+		}
+	}
+
+	// user1 queues three files, user2 one after them, and they take turns.
+	for _, d := range []struct {
+		state    *State
+		name     string
+		position int
+	}{{state1, "a", 1}, {state1, "b", 2}, {state1, "c", 3}, {state2, "d", 2}} {
+		download(d.state, d.name)
+
+		next(func(e event) bool {
+			return e.name == d.name && e.State == TransferQueued && e.Position == d.position
+		})
+	}
+
+	<-uploader.uploadSlots
+
+	var started []string
+	var times []time.Time
+	var completed int
+
+	deadline := time.After(20 * time.Second)
+	for completed < 4 {
+		select {
+		case <-deadline:
+			require.FailNow(t, "uploads timeout", "started %v", started)
+
+		case e := <-events:
+			switch e.State {
+			case TransferInitializing:
+				started = append(started, e.name)
+				times = append(times, time.Now())
+
+			case TransferCompleted:
+				completed++
+
+			case TransferFailed, TransferCancelled:
+				require.FailNow(t, "upload failed", "%s: %v", e.name, e.Err)
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"a", "d", "b", "c"}, started)
+
+	// One upload at a time: each starts once the one before it is done.
+	for i := 1; i < len(times); i++ {
+		assert.Greater(t, times[i].Sub(times[i-1]), 500*time.Millisecond, "%s after %s", started[i], started[i-1])
+	}
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/server"
+)
+
+const (
+	// wishlistFile is the name of the wishlist file in Config.StateFolder.
+	wishlistFile = "wishlist.gob"
+	// wishlistHits is the buffer size of State.WishlistHits. Hits are dropped if nobody
+	// reads them.
+	wishlistHits = 64
+	// wishHitsPerWish is how many hits of a wish are remembered, so that they are not
+	// sent to WishlistHits again. Older ones are forgotten.
+	wishHitsPerWish = 1000
+	// defaultWishlistInterval is how often a wish is searched for until the server tells
+	// us its interval.
+	defaultWishlistInterval = 12 * time.Minute
+)
+
+// Wish is a search kept in the wishlist. The server lets us send one wishlist search per
+// WishlistInterval, so the wishes take turns.
+type Wish struct {
+	Query string
+	// Filter picks the results that are hits, the zero value takes them all.
+	Filter WishFilter
+	// Download queues every hit with the DownloadManager set by SetWishlistDownloads.
+	Download bool
+}
+
+// WishFilter narrows down the results of a Wish.
+type WishFilter struct {
+	// Extensions are the accepted file extensions without the dot, ie. "flac". Empty
+	// accepts any.
+	Extensions []string
+	// MinSize and MaxSize bound the file size in bytes, zero does not.
+	MinSize uint64
+	MaxSize uint64
+	// FreeSlot only accepts files from users with a free upload slot.
+	FreeSlot bool
+}
+
+// WishlistHit is a result of a wishlist search.
+type WishlistHit struct {
+	Query string
+	File  *File
+}
+
+// match reports whether f passes the filter.
+func (w WishFilter) match(f *File) bool {
+	if w.FreeSlot && !f.FreeSlot {
+		return false
+	}
+
+	if w.MinSize > 0 && f.Size < w.MinSize {
+		return false
+	}
+
+	if w.MaxSize > 0 && f.Size > w.MaxSize {
+		return false
+	}
+
+	if len(w.Extensions) == 0 {
+		return true
+	}
+
+	ext := strings.TrimPrefix(path.Ext(virtualBase(f.Name)), ".")
+
+	return slices.ContainsFunc(w.Extensions, func(e string) bool {
+		return strings.EqualFold(strings.TrimPrefix(e, "."), ext)
+	})
+}
+
+// AddWish adds w to the wishlist, replacing the wish with the same query if there is one.
+// The wishlist is kept in Config.StateFolder.
+func (s *State) AddWish(w Wish) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.wishes, func(q Wish) bool { return q.Query == w.Query })
+	if i < 0 {
+		s.wishes = append(s.wishes, w)
+	} else {
+		s.wishes[i] = w
+	}
+
+	s.wakeWishlist()
+
+	return save(s.client.config.StateFolder, wishlistFile, s.wishes)
+}
+
+// RemoveWish drops the wish with query from the wishlist.
+func (s *State) RemoveWish(query string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.wishes = slices.DeleteFunc(s.wishes, func(w Wish) bool { return w.Query == query })
+	delete(s.wishHits, query)
+
+	return save(s.client.config.StateFolder, wishlistFile, s.wishes)
+}
+
+// Wishlist returns the wishes in the order they take turns.
+func (s *State) Wishlist() []Wish {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.wishes)
+}
+
+// SetWishlistDownloads sets the DownloadManager that hits of wishes with Download set are
+// queued with. Files it has queued already, finished or not, are not queued again.
+func (s *State) SetWishlistDownloads(m *DownloadManager) {
+	s.mu.Lock()
+	s.wishlistDownloads = m
+	s.mu.Unlock()
+}
+
+// loadWishlist reads the wishlist saved in folder.
+func loadWishlist(folder string) ([]Wish, error) {
+	var wishes []Wish
+	_, err := load(folder, wishlistFile, &wishes)
+
+	return wishes, err
+}
+
+// setWishlistInterval sets how often the server lets us send a wishlist search.
+func (s *State) setWishlistInterval(interval time.Duration) {
+	s.mu.Lock()
+	s.wishlistInterval = interval
+	s.wakeWishlist()
+	s.mu.Unlock()
+}
+
+// wakeWishlist wakes the wishlist scheduler up to pick up a change.
+func (s *State) wakeWishlist() {
+	select {
+	case s.wishWake <- struct{}{}:
+	default:
+	}
+}
+
+// wishlist sends a wishlist search every WishlistInterval until ctx is done, the wishes
+// taking turns. The responses to each are collected until the next one is sent.
+func (s *State) wishlist(ctx context.Context) {
+	var last time.Time
+	var previous string
+
+	for {
+		s.mu.RLock()
+		wishes := slices.Clone(s.wishes)
+		interval := s.wishlistInterval
+		s.mu.RUnlock()
+
+		if interval <= 0 {
+			interval = defaultWishlistInterval
+		}
+
+		wait := time.Until(last.Add(interval))
+		if len(wishes) == 0 || wait > 0 {
+			// With no wishes there is nothing to wait for but a change.
+			var timer *time.Timer
+			var due <-chan time.Time
+			if len(wishes) > 0 {
+				timer = time.NewTimer(wait)
+				due = timer.C
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-due:
+			case <-s.wishWake:
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			continue
+		}
+
+		// The wish after the one searched last, so that removing a wish does not skip
+		// the next one's turn.
+		i := slices.IndexFunc(wishes, func(w Wish) bool { return w.Query == previous })
+		w := wishes[(i+1)%len(wishes)]
+
+		last, previous = time.Now(), w.Query
+
+		err := s.wish(ctx, w.Query, interval)
+		if err != nil {
+			s.log.Warn().Err(err).Str("query", w.Query).Msg("wishlist search")
+		}
+	}
+}
+
+// wish sends a wishlist search for query and collects its hits for the interval.
+func (s *State) wish(ctx context.Context, query string, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+
+	token := soul.NewToken()
+	session, err := s.session(ctx, query, token, func(conn io.Writer) error {
+		_, err := server.Write(conn, &server.WishlistSearch{Token: token, SearchQuery: query})
+		return err
+	}, false)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	go func() {
+		defer cancel()
+
+		for f := range session.Results {
+			s.hit(query, f)
+		}
+	}()
+
+	return nil
+}
+
+// hit passes f, a result of the wishlist search for query, to WishlistHits if the wish
+// is still in the wishlist and f is a new hit, and queues it for download if the wish
+// asks for it.
+func (s *State) hit(query string, f *File) {
+	s.mu.Lock()
+	i := slices.IndexFunc(s.wishes, func(w Wish) bool { return w.Query == query })
+	if i < 0 || !s.wishes[i].Filter.match(f) {
+		s.mu.Unlock()
+		return
+	}
+
+	hits, found := s.wishHits[query]
+	if !found {
+		hits = &wishHits{seen: make(map[string]bool)}
+		s.wishHits[query] = hits
+	}
+
+	if !hits.add(f.Username + "\x00" + f.Name) {
+		s.mu.Unlock()
+		return
+	}
+
+	w, m := s.wishes[i], s.wishlistDownloads
+	s.mu.Unlock()
+
+	select {
+	case s.WishlistHits <- &WishlistHit{Query: query, File: f}:
+	default:
+	}
+
+	if !w.Download || m == nil {
+		return
+	}
+
+	err := m.want(f)
+	if err != nil {
+		s.log.Warn().Err(err).Str("query", query).Msg("wishlist download")
+	}
+}
+
+// wishHits are the latest hits of a wish, by username and file, oldest first.
+type wishHits struct {
+	seen  map[string]bool
+	order []string
+}
+
+// add records the hit key, forgetting the oldest one past wishHitsPerWish. It reports
+// whether key is a new hit.
+func (h *wishHits) add(key string) bool {
+	if h.seen[key] {
+		return false
+	}
+
+	h.seen[key] = true
+	h.order = append(h.order, key)
+
+	if len(h.order) > wishHitsPerWish {
+		delete(h.seen, h.order[0])
+		h.order = slices.Delete(h.order, 0, 1)
+	}
+
+	return true
+}
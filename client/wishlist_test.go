@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWishlist(t *testing.T) {
+	t.Parallel()
+
+	s := fakeServer(t)
+	s.WishlistInterval = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state1 := login(ctx, t, s, "user1", "")
+	state2 := login(ctx, t, s, "user2", library(t))
+
+	go respond(ctx, state2)
+
+	m, err := NewDownloadManager(state1)
+	require.NoError(t, err)
+
+	state1.SetWishlistDownloads(m)
+
+	// respond answers every search with the mp3, the filter leaves it out.
+	require.NoError(t, state1.AddWish(Wish{Query: "flac", Filter: WishFilter{Extensions: []string{"flac"}}}))
+	require.NoError(t, state1.AddWish(Wish{Query: "mp3", Filter: WishFilter{Extensions: []string{"mp3"}}, Download: true}))
+
+	// Both wishes are searched for in turn, the second after an interval.
+	select {
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "wishlist timeout")
+
+	case hit := <-state1.WishlistHits:
+		assert.Equal(t, "mp3", hit.Query)
+		assert.Equal(t, "user2", hit.File.Username)
+	}
+
+	require.Eventually(t, func() bool { return len(m.Downloads()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, state1.RemoveWish("flac"))
+	assert.Equal(t, []Wish{{Query: "mp3", Filter: WishFilter{Extensions: []string{"mp3"}}, Download: true}}, state1.Wishlist())
+
+	saved, err := loadWishlist(state1.client.config.StateFolder)
+	require.NoError(t, err)
+	assert.Equal(t, state1.Wishlist(), saved)
+}
+
+func TestWishlistHit(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultConfig()
+	config.StateFolder = t.TempDir()
+
+	state := &State{
+		client:       &Client{config: config},
+		WishlistHits: make(chan *WishlistHit, wishlistHits),
+		wishHits:     make(map[string]*wishHits),
+		wishes:       []Wish{{Query: "track", Download: true}},
+	}
+
+	m, err := NewDownloadManager(state)
+	require.NoError(t, err)
+
+	state.SetWishlistDownloads(m)
+
+	f := &File{Username: "user1", File: &peer.File{Name: `@@music\track.flac`, Size: 10}}
+
+	// The same file found again, ie. by the next search, is not a new hit.
+	state.hit("track", f)
+	state.hit("track", f)
+	state.hit("removed", f)
+
+	assert.Len(t, state.WishlistHits, 1)
+
+	downloads := m.Downloads()
+	require.Len(t, downloads, 1)
+	assert.Equal(t, TransferRequested, downloads[0].State)
+
+	// A removed wish forgets its hits.
+	require.NoError(t, state.RemoveWish("track"))
+	assert.Empty(t, state.wishHits)
+
+	// A finished download is not queued again.
+	m.jobs[0].State = TransferCompleted
+	require.NoError(t, m.want(f))
+	assert.Len(t, m.Downloads(), 1)
+	assert.Equal(t, TransferCompleted, m.Downloads()[0].State)
+}
+
+func TestWishHits(t *testing.T) {
+	t.Parallel()
+
+	hits := &wishHits{seen: make(map[string]bool)}
+
+	assert.True(t, hits.add("first"))
+	assert.False(t, hits.add("first"))
+
+	for i := range wishHitsPerWish {
+		assert.True(t, hits.add(strconv.Itoa(i)))
+	}
+
+	// The oldest hit is forgotten, and counts as new again.
+	assert.Len(t, hits.seen, wishHitsPerWish)
+	assert.Len(t, hits.order, wishHitsPerWish)
+	assert.True(t, hits.add("first"))
+	assert.False(t, hits.add(strconv.Itoa(wishHitsPerWish-1)))
+}
+
+func TestWishFilter(t *testing.T) {
+	t.Parallel()
+
+	f := &File{FreeSlot: false, File: &peer.File{Name: `@@music\album\track.FLAC`, Size: 100}}
+
+	tests := map[string]struct {
+		filter WishFilter
+		match  bool
+	}{
+		"any":               {WishFilter{}, true},
+		"extension":         {WishFilter{Extensions: []string{"mp3", "flac"}}, true},
+		"dotted extension":  {WishFilter{Extensions: []string{".flac"}}, true},
+		"other extension":   {WishFilter{Extensions: []string{"mp3"}}, false},
+		"within size":       {WishFilter{MinSize: 100, MaxSize: 100}, true},
+		"too small":         {WishFilter{MinSize: 101}, false},
+		"too big":           {WishFilter{MaxSize: 99}, false},
+		"free slot":         {WishFilter{FreeSlot: true}, false},
+		"extension and big": {WishFilter{Extensions: []string{"flac"}, MaxSize: 10}, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.match, tt.filter.match(f))
+		})
+	}
+}
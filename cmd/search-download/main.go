@@ -1,18 +1,18 @@
 // Description: This example demonstrates how to search for a file and download it.
 // The search is done by sending a search query to the server and reading the results.
+// Results can be narrowed down with -filter, ie. -filter 'ext:flac freeslot -live', see
+// client.ParseFilter.
 package main
 
 import (
 	"context"
-	"errors"
+	"flag"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/client"
-	"github.com/bh90210/soul/peer"
 	"github.com/gosuri/uilive"
 	"github.com/ipsn/go-adorable"
 	"github.com/joho/godotenv"
@@ -21,9 +21,17 @@ import (
 )
 
 func main() {
-	search := os.Args[1:]
+	expr := flag.String("filter", "", "filter expression the results are narrowed down with, see client.ParseFilter")
+	flag.Parse()
 
-	err := godotenv.Load(".env")
+	search := flag.Args()
+
+	filter, err := client.ParseFilter(*expr)
+	if err != nil {
+		log.Fatal().Err(err).Msg("filter")
+	}
+
+	err = godotenv.Load(".env")
 	if err != nil {
 		log.Fatal().Err(err).Msg("load .env")
 	}
@@ -38,8 +46,6 @@ func main() {
 		SoulSeekAddress:   "localhost",
 		// SoulSeekAddress: "server.slsknet.org",
 		SoulSeekPort:       2242,
-		SharedFolders:      100,
-		SharedFiles:        1000,
 		LogLevel:           zerolog.DebugLevel,
 		Timeout:            60 * time.Second,
 		LoginTimeout:       10 * time.Second,
@@ -49,12 +55,11 @@ func main() {
 		AcceptChildren:     true,
 		Picture:            adorable.Random(),
 		Library:            os.Getenv("SOUL_LIBRARY"),
+		AutoRespond:        true,
+		MaxSearchResults:   100,
 		Description:        "soul client",
 	}
 
-	// TODO: delete this.
-	config.LoginTimeout = time.Second
-
 	// Setup logger.
 	log.Logger = log.Level(config.LogLevel)
 	logger := log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
@@ -77,60 +82,18 @@ func main() {
 	// We need the state to login search and download.
 	state := client.NewState(c)
 
-	err = state.Login(ctx)
+	result, err := state.Login(ctx)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("login")
 	}
 
-	logger.Info().Str("username", config.Username).Msg("logged in")
-
-	// Listen for incoming search requests.
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-
-			case request := <-state.Incoming:
-				ctx, _ := context.WithTimeout(ctx, 5*time.Minute) // TODO: timeout from new config field?
-				f, err := os.OpenFile(os.Getenv("SOUL_TESTFILE"), os.O_RDONLY, 0644)
-				if err != nil {
-					logger.Error().Err(err).Msg("read file")
-					continue
-				}
+	logger.Info().Str("username", config.Username).Str("greet", result.Greet).Msg("logged in")
 
-				info, err := f.Stat()
-				if err != nil {
-					logger.Error().Err(err).Msg("stat file")
-					continue
-				}
-
-				files := []*client.File{
-					{
-						Username: request.Username,
-						Token:    request.Token,
-						Queue:    0,
-						File: &peer.File{
-							Name:       f.Name(),
-							Size:       uint64(info.Size()),
-							Extension:  filepath.Ext(f.Name()),
-							Attributes: []peer.Attribute{{Code: 1}},
-						},
-					},
-				}
-
-				err = state.Respond(ctx, files)
-				if err != nil {
-					logger.Error().Err(err).Msg("respond")
-				}
-			}
-		}
-	}()
-
-	// TODO: delete this.
-	// if len(search) == 0 {
-	select {}
-	// }
+	// Without a query we only respond to incoming searches.
+	if len(search) == 0 {
+		<-ctx.Done()
+		return
+	}
 
 	// Our search token.
 	token := soul.NewToken()
@@ -146,7 +109,10 @@ func main() {
 		logger.Fatal().Err(err).Msg("search")
 	}
 
-	logger.Info().Any("token", token).Str("query", query).Msg("searching")
+	logger.Info().Any("token", token).Str("query", query).Str("filter", *expr).Msg("searching")
+
+	// Only the results that pass the filter, all of them without one.
+	results = filter.Apply(searchCtx, results)
 
 	// This is purely for demonstration purposes.
 	// We use uilive to show the search results in one line.
@@ -155,52 +121,41 @@ func main() {
 
 	for {
 		// Start reading the results.
-		result := <-results
-		if result == nil {
-			continue
+		result, ok := <-results
+		if !ok {
+			logger.Fatal().Msg("search ended without a download")
 		}
 
 		logger.Info().Str("username", result.Username).Any("result", result).Msg("search result")
 
 		if result.Queue == 0 && result.Size != 0 {
-			// var download bool
-			// // Filter only lossless files available for download immediately.
-			// for _, attribute := range result.File.Attributes {
-			// 	if attribute.Code == 4 {
-			// 		download = true
-			// 		break
-			// 	}
-			// }
-
-			// if !download {
-			// 	continue
-			// }
-
 			downloadCtx, downloadCancel := context.WithCancel(ctx)
 			defer downloadCancel()
 
-			statusD, errS := state.Download(downloadCtx, result)
+			transfer, err := state.Download(downloadCtx, result)
+			if err != nil {
+				logger.Warn().Str("file", result.Name).Str("peer", result.Username).Err(err).Msg("download error")
+				continue
+			}
 
 			logger.Info().Str("file", result.Name).Str("peer", result.Username).Msg("downloading")
 			logger = log.Output(zerolog.ConsoleWriter{Out: writer})
 
-			for {
-				select {
-				case s := <-statusD:
-					logger.Info().Str("file", result.Name).Str("peer", result.Username).Str("status", s).Msg("download status")
-					continue
-
-				case e := <-errS:
-					if errors.Is(e, peer.ErrComplete) {
-						writer.Stop()
-						logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-						logger.Info().Str("file", result.Name).Str("peer", result.Username).Msg("download complete")
-						downloadCancel()
-						return
-					}
-
-					logger.Warn().Str("file", result.Name).Str("peer", result.Username).Err(e).Msg("download error")
+			for e := range transfer.Events {
+				switch e.State {
+				case client.TransferCompleted:
+					writer.Stop()
+					logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+					logger.Info().Str("file", result.Name).Str("peer", result.Username).Msg("download complete")
 					return
+
+				case client.TransferFailed, client.TransferCancelled:
+					logger.Warn().Str("file", result.Name).Str("peer", result.Username).Err(e.Err).Msg("download error")
+					return
+
+				default:
+					logger.Info().Str("file", result.Name).Str("peer", result.Username).Str("status", string(e.State)).
+						Int("position", e.Position).Int64("bytes", e.Bytes).Int64("total", e.Total).Int64("rate", e.Rate).Msg("download status")
 				}
 			}
 		}
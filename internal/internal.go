@@ -246,17 +246,18 @@ func deobfuscate(connection io.Reader, isInit bool) (message *bytes.Buffer, size
 			}
 
 			readSoFar += int64(n)
-
-			// If there are less than 4 bytes left to read, change the length variable (default 4) to reflect the fact.
-			if (size - uint32(readSoFar)) < 4 {
-				length = int64(size) - readSoFar
-			}
 		}
 
 		// We reached the end of the message.
-		if readSoFar == int64(size) {
+		if readSoFar >= int64(size) {
 			break
 		}
+
+		// If there are less than 4 bytes left to read, change the length variable (default 4) to reflect the fact.
+		// This holds right after the code too, ie. for init messages of 5 bytes.
+		if l > 0 && (size-uint32(readSoFar)) < 4 {
+			length = int64(size) - readSoFar
+		}
 	}
 
 	return
@@ -589,3 +590,13 @@ func ReadIP(val uint32) net.IP {
 
 	return ip
 }
+
+// WriteIP writes an IPv4 address as uint32. Addresses that are not IPv4 are written as zero.
+func WriteIP(buf io.Writer, ip net.IP) error {
+	var val uint32
+	if ip4 := ip.To4(); ip4 != nil {
+		val = binary.BigEndian.Uint32(ip4)
+	}
+
+	return WriteUint32(buf, val)
+}
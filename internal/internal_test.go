@@ -112,6 +112,81 @@ func TestMessageRead(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, uint32(token), m)
 	})
+
+	t.Run("Obfuscated Uint8", func(t *testing.T) {
+		message := new(bytes.Buffer)
+
+		err := WriteUint8(message, 0) // Code.
+		assert.NoError(t, err)
+
+		token := soul.NewToken()
+		err = WriteUint32(message, uint32(token)) // Data.
+		assert.NoError(t, err)
+
+		b, err := Pack(message.Bytes())
+		assert.NoError(t, err)
+
+		message.Reset()
+		n, err := MessageWrite(message, b, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 13, n)
+
+		// The message must be read to its end and no further, a connection would block.
+		r, size, code, err := MessageRead(CodePeerInit(0), io.LimitReader(message, int64(n)), true)
+		require.NoError(t, err)
+		require.Equal(t, uint32(5), size)
+		assert.Equal(t, CodePeerInit(0), code)
+		require.NotNil(t, r)
+
+		s, err := ReadUint32(r) // Size.
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(5), s)
+
+		c, err := ReadUint8(r) // Code.
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(0), c)
+
+		m, err := ReadUint32(r) // Data.
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(token), m)
+	})
+
+	t.Run("Obfuscated odd length", func(t *testing.T) {
+		message := new(bytes.Buffer)
+
+		err := WriteUint32(message, 1) // Code.
+		assert.NoError(t, err)
+
+		err = WriteString(message, "abcdefg") // Data, not a multiple of 4 bytes.
+		assert.NoError(t, err)
+
+		b, err := Pack(message.Bytes())
+		assert.NoError(t, err)
+
+		message.Reset()
+		n, err := MessageWrite(message, b, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 23, n)
+
+		// The message must be read to its end and no further, a connection would block.
+		r, size, code, err := MessageRead(CodePeer(0), io.LimitReader(message, int64(n)), true)
+		require.NoError(t, err)
+		require.Equal(t, uint32(15), size)
+		assert.Equal(t, CodePeer(1), code)
+		require.NotNil(t, r)
+
+		s, err := ReadUint32(r) // Size.
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(15), s)
+
+		c, err := ReadUint32(r) // Code.
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1), c)
+
+		m, err := ReadString(r) // Data.
+		assert.NoError(t, err)
+		assert.Equal(t, "abcdefg", m)
+	})
 }
 
 func TestMessageWrite(t *testing.T) {
@@ -473,3 +548,15 @@ func TestReadIP(t *testing.T) {
 	actual := ReadIP(1)
 	assert.Equal(t, net.IP{0, 0, 0, 1}, actual)
 }
+
+func TestWriteIP(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	err := WriteIP(buf, net.IPv4(127, 0, 0, 1))
+	assert.NoError(t, err)
+
+	val, err := ReadUint32(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, net.IP{127, 0, 0, 1}, ReadIP(val))
+}
@@ -14,8 +14,8 @@ func TestFolderContentsResponse(t *testing.T) {
 	fcr := new(FolderContentsResponse)
 	fcr.Token = soul.NewToken()
 	fcr.Folder = "test"
-	folder := Folder{
-		Directory: "test",
+	folder := Directory{
+		Name: "test",
 		Files: []File{
 			{
 				Name:      "test",
@@ -30,7 +30,7 @@ func TestFolderContentsResponse(t *testing.T) {
 			},
 		},
 	}
-	fcr.Folders = []Folder{folder}
+	fcr.Folders = []Directory{folder}
 	message, err := fcr.Serialize(fcr)
 	assert.NoError(t, err)
 	assert.NotNil(t, message)
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptChildrenRequest(t *testing.T) {
+	t.Parallel()
+
+	ac := new(AcceptChildren)
+	ac.Accept = true
+	message, err := ac.Serialize(ac)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(AcceptChildren)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ac, des)
+}
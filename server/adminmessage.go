@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -38,3 +39,19 @@ func (a *AdminMessage) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts an AdminMessage and returns the message the server sends to clients.
+func (a *AdminMessage) SerializeResponse(message *AdminMessage) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeAdminMessage))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
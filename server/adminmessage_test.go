@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminMessageResponse(t *testing.T) {
+	t.Parallel()
+
+	am := new(AdminMessage)
+	am.Message = "message"
+	message, err := am.SerializeResponse(am)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(AdminMessage)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, am, des)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchLevelRequest(t *testing.T) {
+	t.Parallel()
+
+	bl := new(BranchLevel)
+	bl.Level = 1
+	message, err := bl.Serialize(bl)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(BranchLevel)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, bl, des)
+}
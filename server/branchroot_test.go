@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchRootRequest(t *testing.T) {
+	t.Parallel()
+
+	br := new(BranchRoot)
+	br.Root = "root"
+	message, err := br.Serialize(br)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(BranchRoot)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, br, des)
+}
@@ -65,3 +65,55 @@ func (c *CantConnectToPeer) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a CantConnectToPeer
+// message as it is sent by a client.
+func (c *CantConnectToPeer) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 1001
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeCantConnectToPeer) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeCantConnectToPeer, code))
+	}
+
+	c.Token, err = internal.ReadUint32ToToken(reader)
+	if err != nil {
+		return err
+	}
+
+	c.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a CantConnectToPeer and returns the message the server sends to clients.
+func (c *CantConnectToPeer) SerializeResponse(message *CantConnectToPeer) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeCantConnectToPeer))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Token))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCantConnectToPeerRequest(t *testing.T) {
+	t.Parallel()
+
+	cctp := new(CantConnectToPeer)
+	cctp.Token = soul.Token(1)
+	cctp.Username = "username"
+	message, err := cctp.Serialize(cctp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(CantConnectToPeer)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, cctp, des)
+}
+
+func TestCantConnectToPeerResponse(t *testing.T) {
+	t.Parallel()
+
+	cctp := new(CantConnectToPeer)
+	cctp.Token = soul.Token(1)
+	cctp.Username = "username"
+	message, err := cctp.SerializeResponse(cctp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(CantConnectToPeer)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, cctp, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -38,3 +39,19 @@ func (c *CantCreateRoom) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a CantCreateRoom and returns the message the server sends to clients.
+func (c *CantCreateRoom) SerializeResponse(message *CantCreateRoom) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeCantCreateRoom))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
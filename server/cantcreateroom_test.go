@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCantCreateRoomResponse(t *testing.T) {
+	t.Parallel()
+
+	ccr := new(CantCreateRoom)
+	ccr.Room = "room"
+	message, err := ccr.SerializeResponse(ccr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(CantCreateRoom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ccr, des)
+}
@@ -54,3 +54,45 @@ func (c *ChangePassword) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a ChangePassword
+// message as it is sent by a client.
+func (c *ChangePassword) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 142
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeChangePassword) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeChangePassword, code))
+	}
+
+	c.Pass, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a ChangePassword and returns the message the server sends to clients.
+func (c *ChangePassword) SerializeResponse(message *ChangePassword) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeChangePassword))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Pass)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
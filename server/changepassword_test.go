@@ -0,0 +1,38 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangePasswordRequest(t *testing.T) {
+	t.Parallel()
+
+	cp := new(ChangePassword)
+	cp.Pass = "pass"
+	message, err := cp.Serialize(cp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ChangePassword)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, cp, des)
+}
+
+func TestChangePasswordResponse(t *testing.T) {
+	t.Parallel()
+
+	cp := new(ChangePassword)
+	cp.Pass = "pass"
+	message, err := cp.SerializeResponse(cp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ChangePassword)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, cp, des)
+}
@@ -49,3 +49,40 @@ func (c *CheckPrivileges) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a CheckPrivileges
+// message as it is sent by a client.
+func (c *CheckPrivileges) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 92
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeCheckPrivileges) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeCheckPrivileges, code))
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a CheckPrivileges and returns the message the server sends to clients.
+func (c *CheckPrivileges) SerializeResponse(message *CheckPrivileges) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeCheckPrivileges))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.TimeLeft))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPrivilegesRequest(t *testing.T) {
+	t.Parallel()
+
+	cp := new(CheckPrivileges)
+	message, err := cp.Serialize(cp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(CheckPrivileges)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, cp, des)
+}
+
+func TestCheckPrivilegesResponse(t *testing.T) {
+	t.Parallel()
+
+	cp := new(CheckPrivileges)
+	cp.TimeLeft = 1
+	message, err := cp.SerializeResponse(cp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(CheckPrivileges)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, cp, des)
+}
@@ -105,3 +105,87 @@ func (c *ConnectToPeer) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a ConnectToPeer
+// message as it is sent by a client.
+func (c *ConnectToPeer) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 18
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeConnectToPeer) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeConnectToPeer, code))
+	}
+
+	c.Token, err = internal.ReadUint32ToToken(reader)
+	if err != nil {
+		return err
+	}
+
+	c.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	connType, err := internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	c.Type = soul.ConnectionType(connType)
+
+	return nil
+}
+
+// SerializeResponse accepts a ConnectToPeer and returns the message the server sends to clients.
+func (c *ConnectToPeer) SerializeResponse(message *ConnectToPeer) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeConnectToPeer))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, string(message.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteIP(buf, message.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Token))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBool(buf, message.Privileged)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.ObfuscatedPort))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
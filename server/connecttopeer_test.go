@@ -0,0 +1,48 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectToPeerRequest(t *testing.T) {
+	t.Parallel()
+
+	ctp := new(ConnectToPeer)
+	ctp.Token = soul.Token(1)
+	ctp.Username = "username"
+	ctp.Type = soul.ConnectionType("P")
+	message, err := ctp.Serialize(ctp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ConnectToPeer)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ctp, des)
+}
+
+func TestConnectToPeerResponse(t *testing.T) {
+	t.Parallel()
+
+	ctp := new(ConnectToPeer)
+	ctp.Username = "username"
+	ctp.Type = soul.ConnectionType("P")
+	ctp.IP = net.IP{127, 0, 0, 1}
+	ctp.Port = 1
+	ctp.Token = soul.Token(2)
+	ctp.Privileged = true
+	ctp.ObfuscatedPort = 3
+	message, err := ctp.SerializeResponse(ctp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ConnectToPeer)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ctp, des)
+}
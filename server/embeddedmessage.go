@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -52,3 +53,24 @@ func (e *EmbeddedMessage) Deserialize(reader io.Reader) error {
 	return nil
 
 }
+
+// SerializeResponse accepts an EmbeddedMessage and returns the message the server sends to clients.
+func (e *EmbeddedMessage) SerializeResponse(message *EmbeddedMessage) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeEmbeddedMessage))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint8(buf, uint8(message.Code))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBytes(buf, message.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bh90210/soul/distributed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedMessageResponse(t *testing.T) {
+	t.Parallel()
+
+	em := new(EmbeddedMessage)
+	em.Code = distributed.Code(3)
+	em.Message = []byte{1, 2, 3}
+	message, err := em.SerializeResponse(em)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(EmbeddedMessage)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, em, des)
+}
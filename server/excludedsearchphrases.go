@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -48,3 +49,26 @@ func (e *ExcludedSearchPhrases) Deserialize(reader io.Reader) error {
 
 	return err
 }
+
+// SerializeResponse accepts an ExcludedSearchPhrases and returns the message the server sends to clients.
+func (e *ExcludedSearchPhrases) SerializeResponse(message *ExcludedSearchPhrases) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeExcludedSearchPhrases))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Phrases)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range message.Phrases {
+		err = internal.WriteString(buf, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExcludedSearchPhrasesResponse(t *testing.T) {
+	t.Parallel()
+
+	esp := new(ExcludedSearchPhrases)
+	esp.Phrases = []string{"phrase1", "phrase2"}
+	message, err := esp.SerializeResponse(esp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ExcludedSearchPhrases)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, esp, des)
+}
@@ -71,3 +71,60 @@ func (f *FileSearch) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a FileSearch
+// message as it is sent by a client.
+func (f *FileSearch) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 26
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeFileSearch) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeFileSearch, code))
+	}
+
+	f.Token, err = internal.ReadUint32ToToken(reader)
+	if err != nil {
+		return err
+	}
+
+	f.SearchQuery, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a FileSearch and returns the message the server sends to clients.
+func (f *FileSearch) SerializeResponse(message *FileSearch) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeFileSearch))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Token))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.SearchQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
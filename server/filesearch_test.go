@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSearchRequest(t *testing.T) {
+	t.Parallel()
+
+	fs := new(FileSearch)
+	fs.Token = soul.Token(1)
+	fs.SearchQuery = "searchquery"
+	message, err := fs.Serialize(fs)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(FileSearch)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, fs, des)
+}
+
+func TestFileSearchResponse(t *testing.T) {
+	t.Parallel()
+
+	fs := new(FileSearch)
+	fs.Username = "username"
+	fs.Token = soul.Token(1)
+	fs.SearchQuery = "searchquery"
+	message, err := fs.SerializeResponse(fs)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(FileSearch)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, fs, des)
+}
@@ -78,3 +78,60 @@ func (g *GetPeerAddress) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a GetPeerAddress
+// message as it is sent by a client.
+func (g *GetPeerAddress) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 3
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeGetPeerAddress) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeGetPeerAddress, code))
+	}
+
+	g.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a GetPeerAddress and returns the message the server sends to clients.
+func (g *GetPeerAddress) SerializeResponse(message *GetPeerAddress) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeGetPeerAddress))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteIP(buf, message.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.ObfuscatedPort))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPeerAddressRequest(t *testing.T) {
+	t.Parallel()
+
+	gpa := new(GetPeerAddress)
+	gpa.Username = "username"
+	message, err := gpa.Serialize(gpa)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GetPeerAddress)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gpa, des)
+}
+
+func TestGetPeerAddressResponse(t *testing.T) {
+	t.Parallel()
+
+	gpa := new(GetPeerAddress)
+	gpa.Username = "username"
+	gpa.IP = net.IP{127, 0, 0, 1}
+	gpa.Port = 1
+	gpa.ObfuscatedPort = 2
+	message, err := gpa.SerializeResponse(gpa)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GetPeerAddress)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gpa, des)
+}
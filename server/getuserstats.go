@@ -78,3 +78,65 @@ func (g *GetUserStats) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a GetUserStats
+// message as it is sent by a client.
+func (g *GetUserStats) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 36
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeGetUserStats) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeGetUserStats, code))
+	}
+
+	g.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a GetUserStats and returns the message the server sends to clients.
+func (g *GetUserStats) SerializeResponse(message *GetUserStats) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeGetUserStats))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Speed))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Uploads))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Files))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Directories))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
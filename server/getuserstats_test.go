@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserStatsRequest(t *testing.T) {
+	t.Parallel()
+
+	gus := new(GetUserStats)
+	gus.Username = "username"
+	message, err := gus.Serialize(gus)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GetUserStats)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gus, des)
+}
+
+func TestGetUserStatsResponse(t *testing.T) {
+	t.Parallel()
+
+	gus := new(GetUserStats)
+	gus.Username = "username"
+	gus.Speed = 1
+	gus.Uploads = 2
+	gus.Files = 3
+	gus.Directories = 4
+	message, err := gus.SerializeResponse(gus)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GetUserStats)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gus, des)
+}
@@ -69,3 +69,55 @@ func (g *GetUserStatus) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a GetUserStatus
+// message as it is sent by a client.
+func (g *GetUserStatus) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 7
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeGetUserStatus) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeGetUserStatus, code))
+	}
+
+	g.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a GetUserStatus and returns the message the server sends to clients.
+func (g *GetUserStatus) SerializeResponse(message *GetUserStatus) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeGetUserStatus))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Status))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBool(buf, message.Privileged)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
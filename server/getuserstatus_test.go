@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserStatusRequest(t *testing.T) {
+	t.Parallel()
+
+	gus := new(GetUserStatus)
+	gus.Username = "username"
+	message, err := gus.Serialize(gus)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GetUserStatus)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gus, des)
+}
+
+func TestGetUserStatusResponse(t *testing.T) {
+	t.Parallel()
+
+	gus := new(GetUserStatus)
+	gus.Username = "username"
+	gus.Status = StatusOnline
+	gus.Privileged = true
+	message, err := gus.SerializeResponse(gus)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GetUserStatus)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gus, des)
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -32,3 +36,34 @@ func (g *GivePrivileges) Serialize(message *GivePrivileges) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a GivePrivileges
+// message as it is sent by a client.
+func (g *GivePrivileges) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 123
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeGivePrivileges) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeGivePrivileges, code))
+	}
+
+	g.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	g.Days, err = internal.ReadUint32ToInt(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGivePrivilegesRequest(t *testing.T) {
+	t.Parallel()
+
+	gp := new(GivePrivileges)
+	gp.Username = "username"
+	gp.Days = 1
+	message, err := gp.Serialize(gp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(GivePrivileges)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, gp, des)
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -26,3 +30,29 @@ func (h *HaveNoParent) Serialize(message *HaveNoParent) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a HaveNoParent
+// message as it is sent by a client.
+func (h *HaveNoParent) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 71
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeHaveNoParent) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeHaveNoParent, code))
+	}
+
+	h.Have, err = internal.ReadBool(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHaveNoParentRequest(t *testing.T) {
+	t.Parallel()
+
+	hnp := new(HaveNoParent)
+	hnp.Have = true
+	message, err := hnp.Serialize(hnp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(HaveNoParent)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, hnp, des)
+}
@@ -175,11 +175,18 @@ func (j *JoinRoom) Deserialize(reader io.Reader) error {
 		j.Users[i].CountryCode = countryCode
 	}
 
+	// Owner and operators are only sent for private rooms.
 	j.Owner, err = internal.ReadString(reader)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
 	if err != nil {
 		return err
 	}
 
+	j.Private = true
+
 	operators, err := internal.ReadUint32(reader)
 	if err != nil {
 		return err
@@ -196,3 +203,136 @@ func (j *JoinRoom) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a JoinRoom
+// message as it is sent by a client.
+func (j *JoinRoom) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 1
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeJoinRoom) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeJoinRoom, code))
+	}
+
+	j.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	private, err := internal.ReadUint32(reader)
+	if err != nil {
+		return err
+	}
+
+	j.Private = private == 1
+
+	return nil
+}
+
+// SerializeResponse accepts a JoinRoom and returns the message the server sends to clients.
+// Owner and Operators are only written if the room is private.
+func (j *JoinRoom) SerializeResponse(message *JoinRoom) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeJoinRoom))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range message.Users {
+		err = internal.WriteString(buf, u.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range message.Users {
+		err = internal.WriteUint32(buf, uint32(u.Status))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range message.Users {
+		for _, v := range []int{u.AverageSpeed, u.UploadNumber, 0, u.Files, u.Directories} {
+			err = internal.WriteUint32(buf, uint32(v))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range message.Users {
+		err = internal.WriteUint32(buf, uint32(u.FreeSlots))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range message.Users {
+		err = internal.WriteString(buf, u.CountryCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !message.Private {
+		return internal.Pack(buf.Bytes())
+	}
+
+	err = internal.WriteString(buf, message.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Operators)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operator := range message.Operators {
+		err = internal.WriteString(buf, operator)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinRoomRequest(t *testing.T) {
+	t.Parallel()
+
+	jr := new(JoinRoom)
+	jr.Room = "room"
+	jr.Private = true
+	message, err := jr.Serialize(jr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(JoinRoom)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, jr, des)
+}
+
+func TestJoinRoomResponse(t *testing.T) {
+	t.Parallel()
+
+	jr := new(JoinRoom)
+	jr.Room = "room"
+	jr.Users = []User{
+		{Username: "user1", Status: StatusOnline, AverageSpeed: 1, UploadNumber: 2, Files: 3, Directories: 4, FreeSlots: 5, CountryCode: "GR"},
+		{Username: "user2", Status: StatusAway, AverageSpeed: 6, UploadNumber: 7, Files: 8, Directories: 9, FreeSlots: 10, CountryCode: "NL"},
+	}
+	message, err := jr.SerializeResponse(jr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(JoinRoom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, jr, des)
+
+	jr.Private = true
+	jr.Owner = "user1"
+	jr.Operators = []string{"user2"}
+	message, err = jr.SerializeResponse(jr)
+	assert.NoError(t, err)
+
+	des = new(JoinRoom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, jr, des)
+}
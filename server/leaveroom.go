@@ -54,3 +54,45 @@ func (l *LeaveRoom) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a LeaveRoom
+// message as it is sent by a client.
+func (l *LeaveRoom) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 15
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeLeaveRoom) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeLeaveRoom, code))
+	}
+
+	l.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a LeaveRoom and returns the message the server sends to clients.
+func (l *LeaveRoom) SerializeResponse(message *LeaveRoom) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeLeaveRoom))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaveRoomRequest(t *testing.T) {
+	t.Parallel()
+
+	lr := new(LeaveRoom)
+	lr.Room = "room"
+	message, err := lr.Serialize(lr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(LeaveRoom)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, lr, des)
+}
+
+func TestLeaveRoomResponse(t *testing.T) {
+	t.Parallel()
+
+	lr := new(LeaveRoom)
+	lr.Room = "room"
+	message, err := lr.SerializeResponse(lr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(LeaveRoom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, lr, des)
+}
@@ -25,6 +25,10 @@ type Login struct {
 	Greet string
 	IP    net.IP
 	Sum   string
+
+	// Reason is the failure of an unsuccessful login, ie. ErrInvalidPass. Deserialize
+	// sets it besides returning it and SerializeResponse sends a failure if it is set.
+	Reason error
 }
 
 // Serialize accepts a username and password. It will create a new byte array (buffer)
@@ -92,6 +96,7 @@ var ErrInvalidVersion = errors.New("INVALIDVERSION")
 // - ErrInvalidVersion
 // If the error message is not one of the above, it is an unknown error.
 // You can use the custom err variables to check for specific errors in your code.
+// The failure is also kept in Reason.
 func (l *Login) Deserialize(reader io.Reader) error {
 	_, err := internal.ReadUint32(reader) // size
 	if err != nil {
@@ -121,18 +126,21 @@ func (l *Login) Deserialize(reader io.Reader) error {
 
 		switch errMessage {
 		case ErrInvalidUsername.Error():
-			return errors.Join(err, ErrInvalidUsername)
+			l.Reason = ErrInvalidUsername
 
 		case ErrInvalidPass.Error():
-			return errors.Join(err, ErrInvalidPass)
+			l.Reason = ErrInvalidPass
 
 		case ErrInvalidVersion.Error():
-			return errors.Join(err, ErrInvalidVersion)
+			l.Reason = ErrInvalidVersion
+
+		default:
+			// This is not suppose to happen thus we are not
+			// dedicating a new var Err for it.
+			l.Reason = fmt.Errorf("unknown login failure: %s", errMessage)
 		}
 
-		// This is not suppose to happen thus we are not
-		// dedicating a new var Err for it.
-		return errors.Join(err, fmt.Errorf("unknown login failure: %s", errMessage))
+		return errors.Join(err, l.Reason)
 	}
 
 	l.Greet, err = internal.ReadString(reader)
@@ -155,6 +163,93 @@ func (l *Login) Deserialize(reader io.Reader) error {
 	return nil
 }
 
+// DeserializeRequest accepts a reader (from the client connection) and reads the username
+// and password of a login attempt. Protocol versions and the hash are read but not kept.
+func (l *Login) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 1
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeLogin) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeLogin, code))
+	}
+
+	l.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	l.Password, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	_, err = internal.ReadUint32(reader) // major version
+	if err != nil {
+		return err
+	}
+
+	_, err = internal.ReadString(reader) // hash
+	if err != nil {
+		return err
+	}
+
+	_, err = internal.ReadUint32(reader) // minor version
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a Login and returns the message the server sends to clients.
+// If Reason is set a failure is serialized, otherwise a success carrying Greet, IP and Sum.
+func (l *Login) SerializeResponse(message *Login) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeLogin))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBool(buf, message.Reason == nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Reason != nil {
+		err = internal.WriteString(buf, message.Reason.Error())
+		if err != nil {
+			return nil, err
+		}
+
+		return internal.Pack(buf.Bytes())
+	}
+
+	err = internal.WriteString(buf, message.Greet)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteIP(buf, message.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Sum)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
+
 func sum(username string, password string) ([]byte, error) {
 	sum := md5.Sum([]byte(username + password))
 	return internal.NewString(hex.EncodeToString(sum[:]))
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginRequest(t *testing.T) {
+	t.Parallel()
+
+	l := new(Login)
+	l.Username = "username"
+	l.Password = "password"
+	message, err := l.Serialize(l)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(Login)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, l, des)
+}
+
+func TestLoginResponse(t *testing.T) {
+	t.Parallel()
+
+	l := new(Login)
+	l.Greet = "greet"
+	l.IP = net.IP{127, 0, 0, 1}
+	l.Sum = "sum"
+	message, err := l.SerializeResponse(l)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(Login)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, l, des)
+
+	for _, reason := range []error{ErrInvalidUsername, ErrInvalidPass, ErrInvalidVersion} {
+		message, err = l.SerializeResponse(&Login{Reason: reason})
+		assert.NoError(t, err)
+
+		des = new(Login)
+		err = des.Deserialize(bytes.NewReader(message))
+		assert.ErrorIs(t, err, reason)
+		assert.Equal(t, reason, des.Reason)
+	}
+}
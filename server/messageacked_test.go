@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageAckedRequest(t *testing.T) {
+	t.Parallel()
+
+	ma := new(MessageAcked)
+	ma.MessageID = 1
+	message, err := ma.Serialize(ma)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(MessageAcked)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ma, des)
+}
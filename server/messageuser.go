@@ -83,3 +83,70 @@ func (m *MessageUser) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a MessageUser
+// message as it is sent by a client.
+func (m *MessageUser) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 22
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeMessageUser) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeMessageUser, code))
+	}
+
+	m.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	m.Message, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a MessageUser and returns the message the server sends to clients.
+func (m *MessageUser) SerializeResponse(message *MessageUser) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeMessageUser))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Timestamp))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBool(buf, message.New)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
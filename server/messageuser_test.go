@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageUserRequest(t *testing.T) {
+	t.Parallel()
+
+	mu := new(MessageUser)
+	mu.Username = "username"
+	mu.Message = "message"
+	message, err := mu.Serialize(mu)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(MessageUser)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, mu, des)
+}
+
+func TestMessageUserResponse(t *testing.T) {
+	t.Parallel()
+
+	mu := new(MessageUser)
+	mu.UserID = 1
+	mu.Timestamp = 2
+	mu.Username = "username"
+	mu.Message = "message"
+	mu.New = true
+	message, err := mu.SerializeResponse(mu)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(MessageUser)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, mu, des)
+}
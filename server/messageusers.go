@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -39,3 +43,44 @@ func (m *MessageUsers) Serialize(message *MessageUsers) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a MessageUsers
+// message as it is sent by a client.
+func (m *MessageUsers) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 149
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeMessageUsers) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeMessageUsers, code))
+	}
+
+	number, err := internal.ReadUint32(reader)
+	if err != nil {
+		return err
+	}
+
+	m.Usernames = make([]string, 0, number)
+	for range number {
+		v, err := internal.ReadString(reader)
+		if err != nil {
+			return err
+		}
+
+		m.Usernames = append(m.Usernames, v)
+	}
+
+	m.Message, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
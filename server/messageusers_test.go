@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageUsersRequest(t *testing.T) {
+	t.Parallel()
+
+	mu := new(MessageUsers)
+	mu.Usernames = []string{"username1", "username2"}
+	mu.Message = "message"
+	message, err := mu.Serialize(mu)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(MessageUsers)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, mu, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -38,3 +39,19 @@ func (p *ParentMinSpeed) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a ParentMinSpeed and returns the message the server sends to clients.
+func (p *ParentMinSpeed) SerializeResponse(message *ParentMinSpeed) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeParentMinSpeed))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.MinSpeed))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParentMinSpeedResponse(t *testing.T) {
+	t.Parallel()
+
+	pms := new(ParentMinSpeed)
+	pms.MinSpeed = 1
+	message, err := pms.SerializeResponse(pms)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ParentMinSpeed)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pms, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -38,3 +39,19 @@ func (p *ParentSpeedRatio) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a ParentSpeedRatio and returns the message the server sends to clients.
+func (p *ParentSpeedRatio) SerializeResponse(message *ParentSpeedRatio) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeParentSpeedRatio))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.SpeedRatio))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParentSpeedRatioResponse(t *testing.T) {
+	t.Parallel()
+
+	psr := new(ParentSpeedRatio)
+	psr.SpeedRatio = 1
+	message, err := psr.SerializeResponse(psr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ParentSpeedRatio)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, psr, des)
+}
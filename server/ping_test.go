@@ -0,0 +1,22 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingRequest(t *testing.T) {
+	t.Parallel()
+
+	p := new(Ping)
+	message, err := p.Serialize(p)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(Ping)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, p, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -74,3 +75,36 @@ func (p *PossibleParents) Deserialize(reader io.Reader) error {
 
 	return err
 }
+
+// SerializeResponse accepts a PossibleParents and returns the message the server sends to clients.
+func (p *PossibleParents) SerializeResponse(message *PossibleParents) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePossibleParents))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Parents)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, parent := range message.Parents {
+		err = internal.WriteString(buf, parent.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		err = internal.WriteIP(buf, parent.IP)
+		if err != nil {
+			return nil, err
+		}
+
+		err = internal.WriteUint32(buf, uint32(parent.Port))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
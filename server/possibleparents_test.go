@@ -0,0 +1,27 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPossibleParentsResponse(t *testing.T) {
+	t.Parallel()
+
+	pp := new(PossibleParents)
+	pp.Parents = []Parent{
+		{Username: "user1", IP: net.IP{127, 0, 0, 1}, Port: 1},
+		{Username: "user2", IP: net.IP{127, 0, 0, 2}, Port: 2},
+	}
+	message, err := pp.SerializeResponse(pp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PossibleParents)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pp, des)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomAddedResponse(t *testing.T) {
+	t.Parallel()
+
+	pra := new(PrivateRoomAdded)
+	pra.Room = "room"
+	message, err := pra.SerializeResponse(pra)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomAdded)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pra, des)
+}
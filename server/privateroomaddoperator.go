@@ -65,3 +65,55 @@ func (p *PrivateRoomAddOperator) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a PrivateRoomAddOperator
+// message as it is sent by a client.
+func (p *PrivateRoomAddOperator) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 143
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodePrivateRoomAddOperator) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodePrivateRoomAddOperator, code))
+	}
+
+	p.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	p.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a PrivateRoomAddOperator and returns the message the server sends to clients.
+func (p *PrivateRoomAddOperator) SerializeResponse(message *PrivateRoomAddOperator) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivateRoomAddOperator))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomAddOperatorRequest(t *testing.T) {
+	t.Parallel()
+
+	prao := new(PrivateRoomAddOperator)
+	prao.Room = "room"
+	prao.Username = "username"
+	message, err := prao.Serialize(prao)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomAddOperator)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prao, des)
+}
+
+func TestPrivateRoomAddOperatorResponse(t *testing.T) {
+	t.Parallel()
+
+	prao := new(PrivateRoomAddOperator)
+	prao.Room = "room"
+	prao.Username = "username"
+	message, err := prao.SerializeResponse(prao)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomAddOperator)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prao, des)
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomAddUserRequest(t *testing.T) {
+	t.Parallel()
+
+	prau := new(PrivateRoomAddUser)
+	prau.Room = "room"
+	prau.Username = "username"
+	message, err := prau.Serialize(prau)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomAddUser)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prau, des)
+}
+
+func TestPrivateRoomAddUserResponse(t *testing.T) {
+	t.Parallel()
+
+	prau := new(PrivateRoomAddUser)
+	prau.Room = "room"
+	prau.Username = "username"
+	message, err := prau.SerializeResponse(prau)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomAddUser)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prau, des)
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -26,3 +30,29 @@ func (p *PrivateRoomCancelMembership) Serialize(message *PrivateRoomCancelMember
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a PrivateRoomCancelMembership
+// message as it is sent by a client.
+func (p *PrivateRoomCancelMembership) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 136
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodePrivateRoomCancelMembership) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodePrivateRoomCancelMembership, code))
+	}
+
+	p.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
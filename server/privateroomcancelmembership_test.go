@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomCancelMembershipRequest(t *testing.T) {
+	t.Parallel()
+
+	prcm := new(PrivateRoomCancelMembership)
+	prcm.Room = "room"
+	message, err := prcm.Serialize(prcm)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomCancelMembership)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prcm, des)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomDisownRequest(t *testing.T) {
+	t.Parallel()
+
+	prd := new(PrivateRoomDisown)
+	prd.Room = "room"
+	message, err := prd.Serialize(prd)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomDisown)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prd, des)
+}
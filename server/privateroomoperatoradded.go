@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -38,3 +39,19 @@ func (p *PrivateRoomOperatorAdded) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a PrivateRoomOperatorAdded and returns the message the server sends to clients.
+func (p *PrivateRoomOperatorAdded) SerializeResponse(message *PrivateRoomOperatorAdded) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivateRoomOperatorAdded))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomOperatorAddedResponse(t *testing.T) {
+	t.Parallel()
+
+	proa := new(PrivateRoomOperatorAdded)
+	proa.Room = "room"
+	message, err := proa.SerializeResponse(proa)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomOperatorAdded)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, proa, des)
+}
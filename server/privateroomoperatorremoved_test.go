@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomOperatorRemovedResponse(t *testing.T) {
+	t.Parallel()
+
+	pror := new(PrivateRoomOperatorRemoved)
+	pror.Room = "room"
+	message, err := pror.SerializeResponse(pror)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomOperatorRemoved)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pror, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -53,3 +54,31 @@ func (p *PrivateRoomOperators) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a PrivateRoomOperators and returns the message the server sends to clients.
+func (p *PrivateRoomOperators) SerializeResponse(message *PrivateRoomOperators) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivateRoomOperators))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Operators)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range message.Operators {
+		err = internal.WriteString(buf, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
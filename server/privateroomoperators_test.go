@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomOperatorsResponse(t *testing.T) {
+	t.Parallel()
+
+	pro := new(PrivateRoomOperators)
+	pro.Room = "room"
+	pro.Operators = []string{"operator1", "operator2"}
+	message, err := pro.SerializeResponse(pro)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomOperators)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pro, des)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomRemovedResponse(t *testing.T) {
+	t.Parallel()
+
+	prr := new(PrivateRoomRemoved)
+	prr.Room = "room"
+	message, err := prr.SerializeResponse(prr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomRemoved)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prr, des)
+}
@@ -65,3 +65,55 @@ func (p *PrivateRoomRemoveOperator) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a PrivateRoomRemoveOperator
+// message as it is sent by a client.
+func (p *PrivateRoomRemoveOperator) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 144
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodePrivateRoomRemoveOperator) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodePrivateRoomRemoveOperator, code))
+	}
+
+	p.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	p.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a PrivateRoomRemoveOperator and returns the message the server sends to clients.
+func (p *PrivateRoomRemoveOperator) SerializeResponse(message *PrivateRoomRemoveOperator) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivateRoomRemoveOperator))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomRemoveOperatorRequest(t *testing.T) {
+	t.Parallel()
+
+	prro := new(PrivateRoomRemoveOperator)
+	prro.Room = "room"
+	prro.Username = "username"
+	message, err := prro.Serialize(prro)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomRemoveOperator)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prro, des)
+}
+
+func TestPrivateRoomRemoveOperatorResponse(t *testing.T) {
+	t.Parallel()
+
+	prro := new(PrivateRoomRemoveOperator)
+	prro.Room = "room"
+	prro.Username = "username"
+	message, err := prro.SerializeResponse(prro)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomRemoveOperator)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prro, des)
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomRemoveUserRequest(t *testing.T) {
+	t.Parallel()
+
+	prru := new(PrivateRoomRemoveUser)
+	prru.Room = "room"
+	prru.Username = "username"
+	message, err := prru.Serialize(prru)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomRemoveUser)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prru, des)
+}
+
+func TestPrivateRoomRemoveUserResponse(t *testing.T) {
+	t.Parallel()
+
+	prru := new(PrivateRoomRemoveUser)
+	prru.Room = "room"
+	prru.Username = "username"
+	message, err := prru.SerializeResponse(prru)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomRemoveUser)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prru, des)
+}
@@ -54,3 +54,45 @@ func (p *PrivateRoomToggle) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a PrivateRoomToggle
+// message as it is sent by a client.
+func (p *PrivateRoomToggle) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 141
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodePrivateRoomToggle) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodePrivateRoomToggle, code))
+	}
+
+	p.Enabled, err = internal.ReadBool(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a PrivateRoomToggle and returns the message the server sends to clients.
+func (p *PrivateRoomToggle) SerializeResponse(message *PrivateRoomToggle) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivateRoomToggle))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBool(buf, message.Enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomToggleRequest(t *testing.T) {
+	t.Parallel()
+
+	prt := new(PrivateRoomToggle)
+	prt.Enabled = true
+	message, err := prt.Serialize(prt)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomToggle)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prt, des)
+}
+
+func TestPrivateRoomToggleResponse(t *testing.T) {
+	t.Parallel()
+
+	prt := new(PrivateRoomToggle)
+	prt.Enabled = true
+	message, err := prt.SerializeResponse(prt)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomToggle)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, prt, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -53,3 +54,31 @@ func (p *PrivateRoomUsers) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a PrivateRoomUsers and returns the message the server sends to clients.
+func (p *PrivateRoomUsers) SerializeResponse(message *PrivateRoomUsers) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivateRoomUsers))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range message.Users {
+		err = internal.WriteString(buf, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateRoomUsersResponse(t *testing.T) {
+	t.Parallel()
+
+	pru := new(PrivateRoomUsers)
+	pru.Room = "room"
+	pru.Users = []string{"user1", "user2"}
+	message, err := pru.SerializeResponse(pru)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivateRoomUsers)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pru, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -48,3 +49,26 @@ func (p *PrivilegedUsers) Deserialize(reader io.Reader) (err error) {
 
 	return
 }
+
+// SerializeResponse accepts a PrivilegedUsers and returns the message the server sends to clients.
+func (p *PrivilegedUsers) SerializeResponse(message *PrivilegedUsers) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodePrivilegedUsers))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range message.Users {
+		err = internal.WriteString(buf, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
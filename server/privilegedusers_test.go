@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivilegedUsersResponse(t *testing.T) {
+	t.Parallel()
+
+	pu := new(PrivilegedUsers)
+	pu.Users = []string{"user1", "user2"}
+	message, err := pu.SerializeResponse(pu)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(PrivilegedUsers)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, pu, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -31,3 +32,14 @@ func (r *Relogged) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a Relogged and returns the message the server sends to clients.
+func (r *Relogged) SerializeResponse(_ *Relogged) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeRelogged))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
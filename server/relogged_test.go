@@ -0,0 +1,22 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloggedResponse(t *testing.T) {
+	t.Parallel()
+
+	r := new(Relogged)
+	message, err := r.SerializeResponse(r)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(Relogged)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, r, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -31,3 +32,14 @@ func (r *ResetDistributed) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a ResetDistributed and returns the message the server sends to clients.
+func (r *ResetDistributed) SerializeResponse(_ *ResetDistributed) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeResetDistributed))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
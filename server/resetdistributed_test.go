@@ -0,0 +1,22 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetDistributedResponse(t *testing.T) {
+	t.Parallel()
+
+	rd := new(ResetDistributed)
+	message, err := rd.SerializeResponse(rd)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(ResetDistributed)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rd, des)
+}
@@ -69,6 +69,12 @@ func (r *RoomList) Deserialize(reader io.Reader) (err error) {
 		})
 	}
 
+	// The number of rooms is repeated before the user counts.
+	_, err = internal.ReadUint32(reader)
+	if err != nil {
+		return
+	}
+
 	for i := range int(rooms) {
 		var users uint32
 		users, err = internal.ReadUint32(reader)
@@ -100,6 +106,12 @@ func (r *RoomList) Deserialize(reader io.Reader) (err error) {
 		})
 	}
 
+	// The number of rooms is repeated before the user counts.
+	_, err = internal.ReadUint32(reader)
+	if err != nil {
+		return
+	}
+
 	for i := range int(private) {
 		var no uint32
 		no, err = internal.ReadUint32(reader)
@@ -118,7 +130,7 @@ func (r *RoomList) Deserialize(reader io.Reader) (err error) {
 		return
 	}
 
-	var notOwnedPrivateRooms []Room
+	var notOwnedPrivateRooms []*Room
 	for range int(numberOFNotOwnedPrivateRooms) {
 		var name string
 		name, err = internal.ReadString(reader)
@@ -126,12 +138,18 @@ func (r *RoomList) Deserialize(reader io.Reader) (err error) {
 			return
 		}
 
-		notOwnedPrivateRooms = append(notOwnedPrivateRooms, Room{
+		notOwnedPrivateRooms = append(notOwnedPrivateRooms, &Room{
 			Name:    name,
 			Private: true,
 		})
 	}
 
+	// The number of rooms is repeated before the user counts.
+	_, err = internal.ReadUint32(reader)
+	if err != nil {
+		return
+	}
+
 	for i := range int(numberOFNotOwnedPrivateRooms) {
 		var no uint32
 		no, err = internal.ReadUint32(reader)
@@ -142,15 +160,15 @@ func (r *RoomList) Deserialize(reader io.Reader) (err error) {
 		notOwnedPrivateRooms[i].Users = int(no)
 	}
 
-	r.Rooms = append(r.Rooms, ownedPrivateRooms...)
+	r.Rooms = append(r.Rooms, notOwnedPrivateRooms...)
 
-	// Operated private rooms.
+	// Operated private rooms, only their names are sent.
 	numberOfOperatedPrivateRooms, err := internal.ReadUint32(reader)
 	if err != nil {
 		return
 	}
 
-	var operatedPrivateRooms []Room
+	var operatedPrivateRooms []*Room
 	for range int(numberOfOperatedPrivateRooms) {
 		var name string
 		name, err = internal.ReadString(reader)
@@ -158,24 +176,96 @@ func (r *RoomList) Deserialize(reader io.Reader) (err error) {
 			return
 		}
 
-		operatedPrivateRooms = append(operatedPrivateRooms, Room{
+		operatedPrivateRooms = append(operatedPrivateRooms, &Room{
 			Name:     name,
 			Private:  true,
 			Operated: true,
 		})
 	}
 
-	for i := range int(numberOfOperatedPrivateRooms) {
-		var no uint32
-		no, err = internal.ReadUint32(reader)
-		if err != nil && !errors.Is(err, io.EOF) {
-			return
-		}
+	r.Rooms = append(r.Rooms, operatedPrivateRooms...)
+
+	return
+}
 
-		operatedPrivateRooms[i].Users = int(no)
+// DeserializeRequest accepts a reader (from the client connection) and reads a RoomList
+// message as it is sent by a client.
+func (r *RoomList) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
 	}
 
-	r.Rooms = append(r.Rooms, ownedPrivateRooms...)
+	code, err := internal.ReadUint32(reader) // code 64
+	if err != nil {
+		return err
+	}
 
-	return
+	if code != uint32(CodeRoomList) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeRoomList, code))
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a RoomList and returns the message the server sends to clients.
+// Rooms are grouped into public, owned private, not owned private and operated private
+// rooms, in that order. Users are not sent for operated private rooms.
+func (r *RoomList) SerializeResponse(message *RoomList) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeRoomList))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([][]*Room, 4)
+	for _, room := range message.Rooms {
+		switch {
+		case !room.Private:
+			groups[0] = append(groups[0], room)
+
+		case room.Owned:
+			groups[1] = append(groups[1], room)
+
+		case room.Operated:
+			groups[3] = append(groups[3], room)
+
+		default:
+			groups[2] = append(groups[2], room)
+		}
+	}
+
+	for i, rooms := range groups {
+		err = internal.WriteUint32(buf, uint32(len(rooms)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, room := range rooms {
+			err = internal.WriteString(buf, room.Name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Operated private rooms come without user counts.
+		if i == len(groups)-1 {
+			break
+		}
+
+		err = internal.WriteUint32(buf, uint32(len(rooms)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, room := range rooms {
+			err = internal.WriteUint32(buf, uint32(room.Users))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
 }
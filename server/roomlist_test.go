@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomListRequest(t *testing.T) {
+	t.Parallel()
+
+	rl := new(RoomList)
+	message, err := rl.Serialize(rl)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomList)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rl, des)
+}
+
+func TestRoomListResponse(t *testing.T) {
+	t.Parallel()
+
+	rl := new(RoomList)
+	rl.Rooms = []*Room{
+		{Name: "public", Users: 1},
+		{Name: "owned", Users: 2, Private: true, Owned: true},
+		{Name: "private", Users: 3, Private: true},
+		{Name: "operated", Private: true, Operated: true},
+	}
+	message, err := rl.SerializeResponse(rl)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomList)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rl, des)
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomSearchRequest(t *testing.T) {
+	t.Parallel()
+
+	rs := new(RoomSearch)
+	rs.Room = "room"
+	rs.Token = soul.Token(1)
+	rs.SearchQuery = "searchquery"
+	message, err := rs.Serialize(rs)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomSearch)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rs, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -37,6 +38,11 @@ func (r *RoomTicker) Deserialize(reader io.Reader) error {
 			fmt.Errorf("expected code %d, got %d", CodeRoomTicker, code))
 	}
 
+	r.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
 	users, err := internal.ReadUint32(reader)
 	if err != nil {
 		return err
@@ -60,3 +66,36 @@ func (r *RoomTicker) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a RoomTicker and returns the message the server sends to clients.
+func (r *RoomTicker) SerializeResponse(message *RoomTicker) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeRoomTicker))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(len(message.Users)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range message.Users {
+		err = internal.WriteString(buf, user.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		err = internal.WriteString(buf, user.Tickers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
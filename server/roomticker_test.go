@@ -0,0 +1,27 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomTickerResponse(t *testing.T) {
+	t.Parallel()
+
+	rt := new(RoomTicker)
+	rt.Room = "room"
+	rt.Users = []UserTickers{
+		{Username: "user1", Tickers: "ticker1"},
+		{Username: "user2", Tickers: "ticker2"},
+	}
+	message, err := rt.SerializeResponse(rt)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomTicker)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rt, des)
+}
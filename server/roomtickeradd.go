@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -50,3 +51,29 @@ func (r *RoomTickerAdd) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a RoomTickerAdd and returns the message the server sends to clients.
+func (r *RoomTickerAdd) SerializeResponse(message *RoomTickerAdd) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeRoomTickerAdd))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomTickerAddResponse(t *testing.T) {
+	t.Parallel()
+
+	rta := new(RoomTickerAdd)
+	rta.Room = "room"
+	rta.Username = "username"
+	rta.Ticker = "ticker"
+	message, err := rta.SerializeResponse(rta)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomTickerAdd)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rta, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -44,3 +45,24 @@ func (r *RoomTickerRemove) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts a RoomTickerRemove and returns the message the server sends to clients.
+func (r *RoomTickerRemove) SerializeResponse(message *RoomTickerRemove) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeRoomTickerRemove))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomTickerRemoveResponse(t *testing.T) {
+	t.Parallel()
+
+	rtr := new(RoomTickerRemove)
+	rtr.Room = "room"
+	rtr.Username = "username"
+	message, err := rtr.SerializeResponse(rtr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomTickerRemove)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rtr, des)
+}
@@ -2,30 +2,68 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
 const CodeRoomTickerSet Code = 116
 
-type RoomTickerSet struct{}
+type RoomTickerSet struct {
+	Room   string
+	Ticker string
+}
 
-func (r RoomTickerSet) Serialize(room, ticker string) ([]byte, error) {
+func (r *RoomTickerSet) Serialize(message *RoomTickerSet) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	err := internal.WriteUint32(buf, uint32(CodeRoomTickerSet))
 	if err != nil {
 		return nil, err
 	}
 
-	err = internal.WriteString(buf, room)
+	err = internal.WriteString(buf, message.Room)
 	if err != nil {
 		return nil, err
 	}
 
-	err = internal.WriteString(buf, ticker)
+	err = internal.WriteString(buf, message.Ticker)
 	if err != nil {
 		return nil, err
 	}
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a RoomTickerSet
+// message as it is sent by a client.
+func (r *RoomTickerSet) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 116
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeRoomTickerSet) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeRoomTickerSet, code))
+	}
+
+	r.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	r.Ticker, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomTickerSetRequest(t *testing.T) {
+	t.Parallel()
+
+	rts := new(RoomTickerSet)
+	rts.Room = "room"
+	rts.Ticker = "ticker"
+	message, err := rts.Serialize(rts)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(RoomTickerSet)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, rts, des)
+}
@@ -30,7 +30,7 @@ func (s *SayChatroom) Serialize(message *SayChatroom) ([]byte, error) {
 		return nil, err
 	}
 
-	err = internal.WriteString(buf, message.Username)
+	err = internal.WriteString(buf, message.Message)
 	if err != nil {
 		return nil, err
 	}
@@ -71,3 +71,60 @@ func (s *SayChatroom) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a SayChatroom
+// message as it is sent by a client.
+func (s *SayChatroom) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 13
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeSayChatroom) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeSayChatroom, code))
+	}
+
+	s.Room, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	s.Message, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a SayChatroom and returns the message the server sends to clients.
+func (s *SayChatroom) SerializeResponse(message *SayChatroom) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeSayChatroom))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
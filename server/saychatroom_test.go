@@ -0,0 +1,41 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSayChatroomRequest(t *testing.T) {
+	t.Parallel()
+
+	sc := new(SayChatroom)
+	sc.Room = "room"
+	sc.Message = "message"
+	message, err := sc.Serialize(sc)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(SayChatroom)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, sc, des)
+}
+
+func TestSayChatroomResponse(t *testing.T) {
+	t.Parallel()
+
+	sc := new(SayChatroom)
+	sc.Room = "room"
+	sc.Username = "username"
+	sc.Message = "message"
+	message, err := sc.SerializeResponse(sc)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(SayChatroom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, sc, des)
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendUploadSpeedRequest(t *testing.T) {
+	t.Parallel()
+
+	sus := new(SendUploadSpeed)
+	sus.Speed = 1
+	message, err := sus.Serialize(sus)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(SendUploadSpeed)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, sus, des)
+}
@@ -85,3 +85,60 @@ func Write[M message[M]](connection io.Writer, message M) (int, error) {
 
 	return internal.MessageWrite(connection, m, false)
 }
+
+type response[M any] interface {
+	*AdminMessage |
+		*CantConnectToPeer |
+		*CantCreateRoom |
+		*ChangePassword |
+		*CheckPrivileges |
+		*ConnectToPeer |
+		*EmbeddedMessage |
+		*ExcludedSearchPhrases |
+		*FileSearch |
+		*GetPeerAddress |
+		*GetUserStats |
+		*GetUserStatus |
+		*JoinRoom |
+		*LeaveRoom |
+		*Login |
+		*MessageUser |
+		*ParentMinSpeed |
+		*ParentSpeedRatio |
+		*PossibleParents |
+		*PrivateRoomAddOperator |
+		*PrivateRoomAddUser |
+		*PrivateRoomAdded |
+		*PrivateRoomOperatorAdded |
+		*PrivateRoomOperatorRemoved |
+		*PrivateRoomOperators |
+		*PrivateRoomRemoveOperator |
+		*PrivateRoomRemoveUser |
+		*PrivateRoomRemoved |
+		*PrivateRoomToggle |
+		*PrivateRoomUsers |
+		*PrivilegedUsers |
+		*Relogged |
+		*ResetDistributed |
+		*RoomList |
+		*RoomTicker |
+		*RoomTickerAdd |
+		*RoomTickerRemove |
+		*SayChatroom |
+		*UserJoinedRoom |
+		*UserLeftRoom |
+		*WatchUser |
+		*WishlistInterval
+	SerializeResponse(M) ([]byte, error)
+}
+
+// WriteResponse writes a message to a client connection the way the server does.
+// It is the counterpart of Write and can be used to build servers, proxies and test doubles.
+func WriteResponse[M response[M]](connection io.Writer, message M) (int, error) {
+	m, err := message.SerializeResponse(message)
+	if err != nil {
+		return 0, err
+	}
+
+	return internal.MessageWrite(connection, m, false)
+}
@@ -38,3 +38,18 @@ func TestWrite(t *testing.T) {
 	assert.Equal(t, 64, size)
 	assert.Equal(t, CodeLogin, code)
 }
+
+func TestWriteResponse(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	i, err := WriteResponse(buf, &Login{Greet: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, 25, i)
+
+	r, size, code, err := Read(buf)
+	assert.NoError(t, err)
+	assert.NotNil(t, r)
+	assert.Equal(t, 21, size)
+	assert.Equal(t, CodeLogin, code)
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -35,3 +39,34 @@ func (s SetListenPort) Serialize(message *SetListenPort) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a SetListenPort
+// message as it is sent by a client.
+func (s *SetListenPort) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 2
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeSetListenPort) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeSetListenPort, code))
+	}
+
+	s.Port, err = internal.ReadUint32ToInt(reader)
+	if err != nil {
+		return err
+	}
+
+	s.ObfuscatedPort, err = internal.ReadUint32ToInt(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetListenPortRequest(t *testing.T) {
+	t.Parallel()
+
+	slp := new(SetListenPort)
+	slp.Port = 1
+	slp.ObfuscatedPort = 2
+	message, err := slp.Serialize(slp)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(SetListenPort)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, slp, des)
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -26,3 +30,31 @@ func (s *SetStatus) Serialize(message *SetStatus) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a SetStatus
+// message as it is sent by a client.
+func (s *SetStatus) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 28
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeSetStatus) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeSetStatus, code))
+	}
+
+	status, err := internal.ReadUint32(reader)
+	if err != nil {
+		return err
+	}
+
+	s.Status = UserStatus(status)
+
+	return nil
+}
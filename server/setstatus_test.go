@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStatusRequest(t *testing.T) {
+	t.Parallel()
+
+	ss := new(SetStatus)
+	ss.Status = StatusOnline
+	message, err := ss.Serialize(ss)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(SetStatus)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ss, des)
+}
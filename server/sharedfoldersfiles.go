@@ -2,7 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
 
@@ -33,3 +37,34 @@ func (s *SharedFoldersFiles) Serialize(message *SharedFoldersFiles) ([]byte, err
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a SharedFoldersFiles
+// message as it is sent by a client.
+func (s *SharedFoldersFiles) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 35
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeSharedFoldersFiles) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeSharedFoldersFiles, code))
+	}
+
+	s.Directories, err = internal.ReadUint32ToInt(reader)
+	if err != nil {
+		return err
+	}
+
+	s.Files, err = internal.ReadUint32ToInt(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedFoldersFilesRequest(t *testing.T) {
+	t.Parallel()
+
+	sff := new(SharedFoldersFiles)
+	sff.Directories = 1
+	sff.Files = 2
+	message, err := sff.Serialize(sff)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(SharedFoldersFiles)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, sff, des)
+}
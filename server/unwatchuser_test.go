@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnwatchUserRequest(t *testing.T) {
+	t.Parallel()
+
+	uu := new(UnwatchUser)
+	uu.Username = "username"
+	message, err := uu.Serialize(uu)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(UnwatchUser)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, uu, des)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserJoinedRoomResponse(t *testing.T) {
+	t.Parallel()
+
+	ujr := new(UserJoinedRoom)
+	ujr.Room = "room"
+	ujr.Username = "username"
+	ujr.Status = StatusOnline
+	ujr.Speed = 1
+	ujr.Uploads = 2
+	ujr.Files = 3
+	ujr.Directories = 4
+	ujr.Slots = 5
+	ujr.CountryCode = "countrycode"
+	message, err := ujr.SerializeResponse(ujr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(UserJoinedRoom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ujr, des)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -44,3 +45,24 @@ func (u *UserLeftRoom) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// SerializeResponse accepts an UserLeftRoom and returns the message the server sends to clients.
+func (u *UserLeftRoom) SerializeResponse(message *UserLeftRoom) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeUserLeftRoom))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Room)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
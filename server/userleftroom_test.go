@@ -0,0 +1,24 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserLeftRoomResponse(t *testing.T) {
+	t.Parallel()
+
+	ulr := new(UserLeftRoom)
+	ulr.Room = "room"
+	ulr.Username = "username"
+	message, err := ulr.SerializeResponse(ulr)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(UserLeftRoom)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ulr, des)
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
 	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
@@ -39,3 +42,39 @@ func (u *UserSearch) Serialize(message *UserSearch) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads an UserSearch
+// message as it is sent by a client.
+func (u *UserSearch) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 42
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeUserSearch) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeUserSearch, code))
+	}
+
+	u.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	u.Token, err = internal.ReadUint32ToToken(reader)
+	if err != nil {
+		return err
+	}
+
+	u.SearchQuery, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
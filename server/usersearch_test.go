@@ -0,0 +1,26 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserSearchRequest(t *testing.T) {
+	t.Parallel()
+
+	us := new(UserSearch)
+	us.Username = "username"
+	us.Token = soul.Token(1)
+	us.SearchQuery = "searchquery"
+	message, err := us.Serialize(us)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(UserSearch)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, us, des)
+}
@@ -100,3 +100,70 @@ func (w *WatchUser) Deserialize(reader io.Reader) error {
 
 	return nil
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a WatchUser
+// message as it is sent by a client.
+func (w *WatchUser) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 5
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeWatchUser) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeWatchUser, code))
+	}
+
+	w.Username, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SerializeResponse accepts a WatchUser and returns the message the server sends to clients.
+// Stats are only written if the user exists and the country code only if the user is
+// online or away.
+func (w *WatchUser) SerializeResponse(message *WatchUser) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeWatchUser))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteString(buf, message.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteBool(buf, message.Exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !message.Exists {
+		return internal.Pack(buf.Bytes())
+	}
+
+	for _, v := range []int{int(message.Status), message.AverageSpeed, message.UploadNumber, message.Files, message.Directories} {
+		err = internal.WriteUint32(buf, uint32(v))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if message.Status == StatusOnline || message.Status == StatusAway {
+		err = internal.WriteString(buf, message.CountryCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internal.Pack(buf.Bytes())
+}
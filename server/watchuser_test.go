@@ -0,0 +1,42 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchUserRequest(t *testing.T) {
+	t.Parallel()
+
+	wu := new(WatchUser)
+	wu.Username = "username"
+	message, err := wu.Serialize(wu)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(WatchUser)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, wu, des)
+}
+
+func TestWatchUserResponse(t *testing.T) {
+	t.Parallel()
+
+	for _, wu := range []*WatchUser{
+		{Username: "username"},
+		{Username: "username", Exists: true, Status: StatusOffline, AverageSpeed: 1, UploadNumber: 2, Files: 3, Directories: 4},
+		{Username: "username", Exists: true, Status: StatusOnline, AverageSpeed: 1, UploadNumber: 2, Files: 3, Directories: 4, CountryCode: "GR"},
+	} {
+		message, err := wu.SerializeResponse(wu)
+		assert.NoError(t, err)
+		assert.NotNil(t, message)
+
+		des := new(WatchUser)
+		err = des.Deserialize(bytes.NewReader(message))
+		assert.NoError(t, err)
+		assert.Equal(t, wu, des)
+	}
+}
@@ -1,11 +1,11 @@
 package server
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
-	"errors"
-
 	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
 )
@@ -35,3 +35,19 @@ func (w *WishlistInterval) Deserialize(reader io.Reader) error {
 	w.Interval, err = internal.ReadUint32ToInt(reader)
 	return err
 }
+
+// SerializeResponse accepts a WishlistInterval and returns the message the server sends to clients.
+func (w *WishlistInterval) SerializeResponse(message *WishlistInterval) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := internal.WriteUint32(buf, uint32(CodeWishlistInterval))
+	if err != nil {
+		return nil, err
+	}
+
+	err = internal.WriteUint32(buf, uint32(message.Interval))
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.Pack(buf.Bytes())
+}
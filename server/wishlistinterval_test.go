@@ -0,0 +1,23 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWishlistIntervalResponse(t *testing.T) {
+	t.Parallel()
+
+	wi := new(WishlistInterval)
+	wi.Interval = 1
+	message, err := wi.SerializeResponse(wi)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(WishlistInterval)
+	err = des.Deserialize(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, wi, des)
+}
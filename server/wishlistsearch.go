@@ -2,6 +2,9 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 
 	"github.com/bh90210/soul"
 	"github.com/bh90210/soul/internal"
@@ -33,3 +36,34 @@ func (w *WishlistSearch) Serialize(message *WishlistSearch) ([]byte, error) {
 
 	return internal.Pack(buf.Bytes())
 }
+
+// DeserializeRequest accepts a reader (from the client connection) and reads a WishlistSearch
+// message as it is sent by a client.
+func (w *WishlistSearch) DeserializeRequest(reader io.Reader) error {
+	_, err := internal.ReadUint32(reader) // size
+	if err != nil {
+		return err
+	}
+
+	code, err := internal.ReadUint32(reader) // code 103
+	if err != nil {
+		return err
+	}
+
+	if code != uint32(CodeWishlistSearch) {
+		return errors.Join(soul.ErrMismatchingCodes,
+			fmt.Errorf("expected code %d, got %d", CodeWishlistSearch, code))
+	}
+
+	w.Token, err = internal.ReadUint32ToToken(reader)
+	if err != nil {
+		return err
+	}
+
+	w.SearchQuery, err = internal.ReadString(reader)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bh90210/soul"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWishlistSearchRequest(t *testing.T) {
+	t.Parallel()
+
+	ws := new(WishlistSearch)
+	ws.Token = soul.Token(1)
+	ws.SearchQuery = "searchquery"
+	message, err := ws.Serialize(ws)
+	assert.NoError(t, err)
+	assert.NotNil(t, message)
+
+	des := new(WishlistSearch)
+	err = des.DeserializeRequest(bytes.NewReader(message))
+	assert.NoError(t, err)
+	assert.Equal(t, ws, des)
+}
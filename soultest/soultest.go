@@ -0,0 +1,835 @@
+// Package soultest provides an in-process SoulSeek server for hermetic tests.
+//
+// Server listens on a loopback port and implements the subset of the server protocol
+// a client needs to login, find its peers, search, join rooms and participate in the
+// distributed network. Every user that logs in is kept in memory for the lifetime of the Server.
+package soultest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/bh90210/soul/internal"
+	"github.com/bh90210/soul/server"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// MaxParents is the maximum number of possible parents the server sends at once.
+const MaxParents = 10
+
+// Server is an in-process SoulSeek server.
+type Server struct {
+	// Greet is the message of the day sent with a successful login.
+	Greet string
+	// ParentMinSpeed is sent to users after login.
+	ParentMinSpeed int
+	// ParentSpeedRatio is sent to users after login.
+	ParentSpeedRatio int
+	// WishlistInterval is sent to users after login (seconds).
+	WishlistInterval int
+	// ExcludedSearchPhrases is sent to users after login.
+	ExcludedSearchPhrases []string
+	// LatePhrases sends ExcludedSearchPhrases after the reply to CheckPrivileges, rather
+	// than with the other messages sent after login.
+	LatePhrases bool
+
+	listener net.Listener
+	mu       sync.RWMutex
+	users    map[string]*user
+	sequence int
+	wg       sync.WaitGroup
+
+	log zerolog.Logger
+}
+
+type user struct {
+	username string
+	password string
+	sequence int
+
+	mu   sync.Mutex
+	conn net.Conn
+
+	ip             net.IP
+	port           int
+	obfuscatedPort int
+	status         server.UserStatus
+	privileged     bool
+	firewalled     bool
+	// rejects is the number of logins still to fail, see Server.RejectLogins.
+	rejects     int
+	speed       int
+	uploads     int
+	files       int
+	directories int
+
+	haveNoParent   bool
+	acceptChildren bool
+	branchLevel    int
+	branchRoot     string
+
+	watching map[string]struct{}
+	// rooms are the chat rooms the user joined.
+	rooms map[string]struct{}
+}
+
+// NewServer starts a Server listening on a random loopback port.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Greet:            "soultest",
+		ParentMinSpeed:   1,
+		ParentSpeedRatio: 50,
+		WishlistInterval: 720,
+		listener:         l,
+		users:            make(map[string]*user),
+	}
+
+	s.log = log.Output(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.Disabled)
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Host returns the address the server listens to.
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server listens to.
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Users returns the usernames of everyone currently logged in.
+func (s *Server) Users() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var users []string
+	for _, u := range s.users {
+		if u.online() {
+			users = append(users, u.username)
+		}
+	}
+
+	slices.Sort(users)
+
+	return users
+}
+
+// Disconnect drops the connection of username as if the network failed. It reports
+// whether the user was online.
+func (s *Server) Disconnect(username string) bool {
+	u := s.user(username)
+	if u == nil || !u.online() {
+		return false
+	}
+
+	u.close()
+
+	return true
+}
+
+// Firewall makes username unreachable for direct connections: peers asking for its
+// address get a closed port and have to ask it to connect to them instead. It reports
+// whether the user exists.
+func (s *Server) Firewall(username string) bool {
+	u := s.user(username)
+	if u == nil {
+		return false
+	}
+
+	u.mu.Lock()
+	u.firewalled = true
+	u.mu.Unlock()
+
+	return true
+}
+
+// RejectLogins makes the next n logins of username fail as if the password was wrong,
+// ie. to fail a reconnect. It reports whether the user exists.
+func (s *Server) RejectLogins(username string, n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, found := s.users[username]
+	if !found {
+		return false
+	}
+
+	u.rejects = n
+
+	return true
+}
+
+// ExcludeSearchPhrases replaces ExcludedSearchPhrases and sends the new list to everyone
+// logged in.
+func (s *Server) ExcludeSearchPhrases(phrases ...string) {
+	s.mu.Lock()
+	s.ExcludedSearchPhrases = phrases
+
+	var users []*user
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	s.mu.Unlock()
+
+	for _, u := range users {
+		send(u, &server.ExcludedSearchPhrases{Phrases: phrases})
+	}
+}
+
+// Close stops the server and disconnects all users.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for _, u := range s.users {
+		u.close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.Warn().Err(err).Msg("accept")
+			}
+
+			return
+		}
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle reads messages from a single client connection until it is closed.
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	var u *user
+	defer func() {
+		if u != nil {
+			s.logout(u, conn)
+		}
+	}()
+
+	for {
+		r, _, code, err := server.Read(conn)
+		if err != nil {
+			return
+		}
+
+		if u == nil {
+			if code != server.CodeLogin {
+				s.log.Warn().Stringer("code", code).Msg("message before login")
+				continue
+			}
+
+			u, err = s.login(conn, r)
+			if err != nil {
+				s.log.Warn().Err(err).Msg("login")
+				return
+			}
+
+			continue
+		}
+
+		err = s.message(u, code, r)
+		if err != nil {
+			s.log.Warn().Err(err).Stringer("code", code).Str("username", u.username).Msg("message")
+		}
+	}
+}
+
+func (s *Server) login(conn net.Conn, r io.Reader) (*user, error) {
+	l := new(server.Login)
+	err := l.DeserializeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	failure := func(reason error) (*user, error) {
+		_, err := server.WriteResponse(conn, &server.Login{Reason: reason})
+		return nil, errors.Join(reason, err)
+	}
+
+	if l.Username == "" || len(l.Username) > 30 {
+		return failure(server.ErrInvalidUsername)
+	}
+
+	s.mu.Lock()
+	u, found := s.users[l.Username]
+	if found && (u.password != l.Password || u.rejects > 0) {
+		if u.password == l.Password {
+			u.rejects--
+		}
+
+		s.mu.Unlock()
+		return failure(server.ErrInvalidPass)
+	}
+
+	if !found {
+		u = &user{
+			username: l.Username,
+			password: l.Password,
+			watching: make(map[string]struct{}),
+			rooms:    make(map[string]struct{}),
+		}
+
+		s.users[l.Username] = u
+	}
+
+	// A second login with the same credentials kicks the previous session.
+	if u.online() {
+		send(u, &server.Relogged{})
+		u.close()
+	}
+
+	s.sequence++
+
+	u.mu.Lock()
+	u.conn = conn
+	u.ip = conn.RemoteAddr().(*net.TCPAddr).IP.To4()
+	u.status = server.StatusOnline
+	u.sequence = s.sequence
+	u.haveNoParent = false
+	u.mu.Unlock()
+	s.mu.Unlock()
+
+	sum := md5.Sum([]byte(l.Password))
+
+	send(u, &server.Login{Greet: s.Greet, IP: u.ip, Sum: hex.EncodeToString(sum[:])})
+
+	s.afterLogin(u)
+
+	s.notify(u)
+
+	s.log.Debug().Str("username", l.Username).Msg("logged in")
+
+	return u, nil
+}
+
+// afterLogin sends the messages the official server sends to every user once logged in.
+func (s *Server) afterLogin(u *user) {
+	send(u, &server.RoomList{})
+	send(u, &server.ParentMinSpeed{MinSpeed: s.ParentMinSpeed})
+	send(u, &server.ParentSpeedRatio{SpeedRatio: s.ParentSpeedRatio})
+	send(u, &server.WishlistInterval{Interval: s.WishlistInterval})
+
+	s.mu.RLock()
+	var privileged []string
+	for _, p := range s.users {
+		if p.privileged {
+			privileged = append(privileged, p.username)
+		}
+	}
+
+	phrases := s.ExcludedSearchPhrases
+	late := s.LatePhrases
+	s.mu.RUnlock()
+
+	send(u, &server.PrivilegedUsers{Users: privileged})
+
+	if !late {
+		send(u, &server.ExcludedSearchPhrases{Phrases: phrases})
+	}
+}
+
+func (s *Server) logout(u *user, conn net.Conn) {
+	u.mu.Lock()
+	// The user has logged in again from another connection.
+	if u.conn != conn {
+		u.mu.Unlock()
+		return
+	}
+
+	u.conn = nil
+	u.status = server.StatusOffline
+	u.rooms = make(map[string]struct{})
+	u.mu.Unlock()
+
+	s.notify(u)
+
+	s.log.Debug().Str("username", u.username).Msg("logged out")
+}
+
+// message handles a single message from a logged in user.
+func (s *Server) message(u *user, code server.Code, r io.Reader) error {
+	switch code {
+	case server.CodeSetListenPort:
+		m := new(server.SetListenPort)
+		err := m.DeserializeRequest(r)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		u.mu.Lock()
+		u.port = m.Port
+		u.obfuscatedPort = m.ObfuscatedPort
+		u.mu.Unlock()
+
+	case server.CodeGetPeerAddress:
+		m := new(server.GetPeerAddress)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		m.IP = net.IPv4zero.To4()
+		if p := s.user(m.Username); p != nil && p.online() {
+			p.mu.Lock()
+			m.IP, m.Port, m.ObfuscatedPort = p.ip, p.port, p.obfuscatedPort
+			if p.firewalled {
+				m.Port, m.ObfuscatedPort = 0, 0
+			}
+			p.mu.Unlock()
+		}
+
+		send(u, m)
+
+	case server.CodeWatchUser:
+		m := new(server.WatchUser)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.watching[m.Username] = struct{}{}
+		u.mu.Unlock()
+
+		if w := s.user(m.Username); w != nil {
+			w.mu.Lock()
+			m.Exists = true
+			m.Status = w.status
+			m.AverageSpeed = w.speed
+			m.UploadNumber = w.uploads
+			m.Files = w.files
+			m.Directories = w.directories
+			w.mu.Unlock()
+		}
+
+		send(u, m)
+
+	case server.CodeUnwatchUser:
+		m := new(server.UnwatchUser)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		delete(u.watching, m.Username)
+		u.mu.Unlock()
+
+	case server.CodeGetUserStatus:
+		m := new(server.GetUserStatus)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		m.Status = server.StatusOffline
+		if w := s.user(m.Username); w != nil {
+			w.mu.Lock()
+			m.Status, m.Privileged = w.status, w.privileged
+			w.mu.Unlock()
+		}
+
+		send(u, m)
+
+	case server.CodeGetUserStats:
+		m := new(server.GetUserStats)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		if w := s.user(m.Username); w != nil {
+			w.mu.Lock()
+			m.Speed, m.Uploads, m.Files, m.Directories = w.speed, w.uploads, w.files, w.directories
+			w.mu.Unlock()
+		}
+
+		send(u, m)
+
+	case server.CodeSetStatus:
+		m := new(server.SetStatus)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.status = m.Status
+		u.mu.Unlock()
+
+		s.notify(u)
+
+	case server.CodeSharedFoldersFiles:
+		m := new(server.SharedFoldersFiles)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.directories, u.files = m.Directories, m.Files
+		u.mu.Unlock()
+
+	case server.CodeSendUploadSpeed:
+		m := new(server.SendUploadSpeed)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.speed = m.Speed
+		u.uploads++
+		u.mu.Unlock()
+
+	case server.CodeCheckPrivileges:
+		send(u, &server.CheckPrivileges{})
+
+		s.mu.RLock()
+		phrases, late := s.ExcludedSearchPhrases, s.LatePhrases
+		s.mu.RUnlock()
+
+		if late {
+			send(u, &server.ExcludedSearchPhrases{Phrases: phrases})
+		}
+
+	case server.CodeConnectToPeer:
+		m := new(server.ConnectToPeer)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		s.connectToPeer(u, m)
+
+	case server.CodeFileSearch:
+		m := new(server.FileSearch)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		m.Username = u.username
+
+		s.mu.RLock()
+		for _, p := range s.users {
+			if p == u || !p.online() {
+				continue
+			}
+
+			send(p, m)
+		}
+		s.mu.RUnlock()
+
+	case server.CodeWishlistSearch:
+		m := new(server.WishlistSearch)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		for _, p := range s.users {
+			if p == u || !p.online() {
+				continue
+			}
+
+			send(p, &server.FileSearch{Username: u.username, Token: m.Token, SearchQuery: m.SearchQuery})
+		}
+		s.mu.RUnlock()
+
+	case server.CodeRoomSearch:
+		m := new(server.RoomSearch)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range s.members(m.Room) {
+			if p != u {
+				send(p, &server.FileSearch{Username: u.username, Token: m.Token, SearchQuery: m.SearchQuery})
+			}
+		}
+
+	case server.CodeJoinRoom:
+		m := new(server.JoinRoom)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.rooms[m.Room] = struct{}{}
+		u.mu.Unlock()
+
+		joined := &server.JoinRoom{Room: m.Room}
+		for _, p := range s.members(m.Room) {
+			p.mu.Lock()
+			joined.Users = append(joined.Users, server.User{Username: p.username, Status: p.status})
+			p.mu.Unlock()
+		}
+
+		send(u, joined)
+
+	case server.CodeLeaveRoom:
+		m := new(server.LeaveRoom)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		delete(u.rooms, m.Room)
+		u.mu.Unlock()
+
+		send(u, m)
+
+	case server.CodeUserSearch:
+		m := new(server.UserSearch)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		if p := s.user(m.Username); p != nil && p.online() {
+			send(p, &server.FileSearch{Username: u.username, Token: m.Token, SearchQuery: m.SearchQuery})
+		}
+
+	case server.CodeHaveNoParent:
+		m := new(server.HaveNoParent)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.haveNoParent = m.Have
+		u.mu.Unlock()
+
+		if m.Have {
+			s.possibleParents(u)
+		}
+
+	case server.CodeAcceptChildren:
+		m := new(server.AcceptChildren)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.acceptChildren = m.Accept
+		u.mu.Unlock()
+
+	case server.CodeBranchLevel:
+		m := new(server.BranchLevel)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.branchLevel = m.Level
+		u.mu.Unlock()
+
+	case server.CodeBranchRoot:
+		m := new(server.BranchRoot)
+		err := m.DeserializeRequest(r)
+		if err != nil {
+			return err
+		}
+
+		u.mu.Lock()
+		u.branchRoot = m.Root
+		u.mu.Unlock()
+
+	case server.CodePing:
+
+	default:
+		s.log.Debug().Stringer("code", code).Msg("unhandled message")
+	}
+
+	return nil
+}
+
+// connectToPeer relays a ConnectToPeer request to the target user or tells the
+// requester that the target can not be reached.
+func (s *Server) connectToPeer(from *user, m *server.ConnectToPeer) {
+	to := s.user(m.Username)
+	if to == nil || !to.online() {
+		send(from, &server.CantConnectToPeer{Token: m.Token, Username: m.Username})
+		return
+	}
+
+	from.mu.Lock()
+	relay := &server.ConnectToPeer{
+		Username:       from.username,
+		Type:           m.Type,
+		IP:             from.ip,
+		Port:           from.port,
+		Token:          m.Token,
+		Privileged:     from.privileged,
+		ObfuscatedPort: from.obfuscatedPort,
+	}
+	from.mu.Unlock()
+
+	send(to, relay)
+}
+
+// possibleParents sends the user a list of users that logged in before them and
+// accept children. Offering only earlier users keeps the distributed network a tree.
+func (s *Server) possibleParents(u *user) {
+	s.mu.RLock()
+	var parents []*user
+	for _, p := range s.users {
+		if p == u || !p.online() {
+			continue
+		}
+
+		p.mu.Lock()
+		ok := p.acceptChildren && p.sequence < u.sequence
+		p.mu.Unlock()
+
+		if ok {
+			parents = append(parents, p)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(parents) == 0 {
+		return
+	}
+
+	slices.SortFunc(parents, func(a, b *user) int { return a.sequence - b.sequence })
+	if len(parents) > MaxParents {
+		parents = parents[:MaxParents]
+	}
+
+	m := new(server.PossibleParents)
+	for _, parent := range parents {
+		parent.mu.Lock()
+		m.Parents = append(m.Parents, server.Parent{Username: parent.username, IP: parent.ip, Port: parent.port})
+		parent.mu.Unlock()
+	}
+
+	send(u, m)
+}
+
+// notify sends the status of u to everyone watching them.
+func (s *Server) notify(u *user) {
+	u.mu.Lock()
+	m := &server.GetUserStatus{Username: u.username, Status: u.status, Privileged: u.privileged}
+	u.mu.Unlock()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, w := range s.users {
+		if w == u {
+			continue
+		}
+
+		w.mu.Lock()
+		_, watching := w.watching[u.username]
+		w.mu.Unlock()
+
+		if !watching || !w.online() {
+			continue
+		}
+
+		send(w, m)
+	}
+}
+
+func (s *Server) user(username string) *user {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.users[username]
+}
+
+// members returns the online users who joined room.
+func (s *Server) members(room string) []*user {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []*user
+	for _, u := range s.users {
+		u.mu.Lock()
+		_, joined := u.rooms[room]
+		online := u.conn != nil
+		u.mu.Unlock()
+
+		if joined && online {
+			members = append(members, u)
+		}
+	}
+
+	return members
+}
+
+func (u *user) online() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.conn != nil
+}
+
+func (u *user) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.conn != nil {
+		u.conn.Close()
+	}
+}
+
+// send writes a server response to the user's connection. Errors are ignored, a broken
+// connection will be noticed by the reading side.
+func send[M interface{ SerializeResponse(M) ([]byte, error) }](u *user, message M) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.conn == nil {
+		return
+	}
+
+	m, err := message.SerializeResponse(message)
+	if err != nil {
+		return
+	}
+
+	internal.MessageWrite(u.conn, m, false)
+}
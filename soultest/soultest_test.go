@@ -0,0 +1,464 @@
+package soultest
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bh90210/soul"
+	"github.com/bh90210/soul/peer"
+	"github.com/bh90210/soul/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn := login(t, s, "user1", "password")
+	defer conn.Close()
+
+	assert.Equal(t, []string{"user1"}, s.Users())
+
+	wrong := dial(t, s)
+	defer wrong.Close()
+
+	_, err = server.Write(wrong, &server.Login{Username: "user1", Password: "wrong"})
+	require.NoError(t, err)
+
+	m := new(server.Login)
+	err = m.Deserialize(read(t, wrong, server.CodeLogin))
+	assert.ErrorIs(t, err, server.ErrInvalidPass)
+}
+
+func TestRejectLogins(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	assert.False(t, s.RejectLogins("user1", 1))
+
+	conn := login(t, s, "user1", "password")
+	conn.Close()
+
+	require.Eventually(t, func() bool { return len(s.Users()) == 0 }, time.Second, 10*time.Millisecond)
+	require.True(t, s.RejectLogins("user1", 1))
+
+	rejected := dial(t, s)
+	defer rejected.Close()
+
+	_, err = server.Write(rejected, &server.Login{Username: "user1", Password: "password"})
+	require.NoError(t, err)
+
+	m := new(server.Login)
+	err = m.Deserialize(read(t, rejected, server.CodeLogin))
+	assert.ErrorIs(t, err, server.ErrInvalidPass)
+
+	conn = login(t, s, "user1", "password")
+	defer conn.Close()
+
+	assert.Equal(t, []string{"user1"}, s.Users())
+}
+
+func TestRelogged(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	first := login(t, s, "user1", "password")
+	defer first.Close()
+
+	second := login(t, s, "user1", "password")
+	defer second.Close()
+
+	m := new(server.Relogged)
+	err = m.Deserialize(read(t, first, server.CodeRelogged))
+	assert.NoError(t, err)
+}
+
+func TestDisconnect(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn := login(t, s, "user1", "password")
+	defer conn.Close()
+
+	assert.True(t, s.Disconnect("user1"))
+	assert.False(t, s.Disconnect("nobody"))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, _, err = server.Read(conn)
+	assert.Error(t, err)
+
+	assert.Eventually(t, func() bool { return len(s.Users()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestExcludeSearchPhrases(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn := login(t, s, "user1", "password")
+	defer conn.Close()
+
+	s.ExcludeSearchPhrases("excluded", "phrase")
+
+	m := new(server.ExcludedSearchPhrases)
+	err = m.Deserialize(read(t, conn, server.CodeExcludedSearchPhrases))
+	require.NoError(t, err)
+
+	// The first one is sent at login.
+	if len(m.Phrases) == 0 {
+		err = m.Deserialize(read(t, conn, server.CodeExcludedSearchPhrases))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"excluded", "phrase"}, m.Phrases)
+}
+
+func TestGetPeerAddress(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	_, err = server.Write(user1, &server.SetListenPort{Port: 2234, ObfuscatedPort: 2235})
+	require.NoError(t, err)
+
+	roundTrip(t, user1)
+
+	user2 := login(t, s, "user2", "password")
+	defer user2.Close()
+
+	_, err = server.Write(user2, &server.GetPeerAddress{Username: "user1"})
+	require.NoError(t, err)
+
+	m := new(server.GetPeerAddress)
+	err = m.Deserialize(read(t, user2, server.CodeGetPeerAddress))
+	require.NoError(t, err)
+	assert.Equal(t, "user1", m.Username)
+	assert.Equal(t, "127.0.0.1", m.IP.String())
+	assert.Equal(t, 2234, m.Port)
+	assert.Equal(t, 2235, m.ObfuscatedPort)
+}
+
+func TestFirewall(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	assert.False(t, s.Firewall("user1"))
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	_, err = server.Write(user1, &server.SetListenPort{Port: 2234, ObfuscatedPort: 2235})
+	require.NoError(t, err)
+
+	roundTrip(t, user1)
+
+	assert.True(t, s.Firewall("user1"))
+
+	user2 := login(t, s, "user2", "password")
+	defer user2.Close()
+
+	_, err = server.Write(user2, &server.GetPeerAddress{Username: "user1"})
+	require.NoError(t, err)
+
+	m := new(server.GetPeerAddress)
+	err = m.Deserialize(read(t, user2, server.CodeGetPeerAddress))
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", m.IP.String())
+	assert.Zero(t, m.Port)
+	assert.Zero(t, m.ObfuscatedPort)
+}
+
+func TestFileSearch(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	user2 := login(t, s, "user2", "password")
+	defer user2.Close()
+
+	user3 := login(t, s, "user3", "password")
+	defer user3.Close()
+
+	token := soul.NewToken()
+	_, err = server.Write(user1, &server.FileSearch{Token: token, SearchQuery: "test"})
+	require.NoError(t, err)
+
+	for _, conn := range []net.Conn{user2, user3} {
+		m := new(server.FileSearch)
+		err = m.Deserialize(read(t, conn, server.CodeFileSearch))
+		require.NoError(t, err)
+		assert.Equal(t, "user1", m.Username)
+		assert.Equal(t, token, m.Token)
+		assert.Equal(t, "test", m.SearchQuery)
+	}
+
+	_, err = server.Write(user1, &server.UserSearch{Username: "user3", Token: token, SearchQuery: "user"})
+	require.NoError(t, err)
+
+	m := new(server.FileSearch)
+	err = m.Deserialize(read(t, user3, server.CodeFileSearch))
+	require.NoError(t, err)
+	assert.Equal(t, "user", m.SearchQuery)
+
+	_, err = server.Write(user1, &server.WishlistSearch{Token: token, SearchQuery: "wish"})
+	require.NoError(t, err)
+
+	for _, conn := range []net.Conn{user2, user3} {
+		m := new(server.FileSearch)
+		err = m.Deserialize(read(t, conn, server.CodeFileSearch))
+		require.NoError(t, err)
+		assert.Equal(t, "user1", m.Username)
+		assert.Equal(t, "wish", m.SearchQuery)
+	}
+}
+
+func TestRoomSearch(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	user2 := login(t, s, "user2", "password")
+	defer user2.Close()
+
+	user3 := login(t, s, "user3", "password")
+	defer user3.Close()
+
+	for _, conn := range []net.Conn{user1, user2} {
+		_, err = server.Write(conn, &server.JoinRoom{Room: "room"})
+		require.NoError(t, err)
+
+		read(t, conn, server.CodeJoinRoom)
+	}
+
+	_, err = server.Write(user3, &server.JoinRoom{Room: "room"})
+	require.NoError(t, err)
+
+	joined := new(server.JoinRoom)
+	err = joined.Deserialize(read(t, user3, server.CodeJoinRoom))
+	require.NoError(t, err)
+	assert.Equal(t, "room", joined.Room)
+	assert.Len(t, joined.Users, 3)
+
+	_, err = server.Write(user3, &server.LeaveRoom{Room: "room"})
+	require.NoError(t, err)
+
+	left := new(server.LeaveRoom)
+	err = left.Deserialize(read(t, user3, server.CodeLeaveRoom))
+	require.NoError(t, err)
+	assert.Equal(t, "room", left.Room)
+
+	token := soul.NewToken()
+	_, err = server.Write(user1, &server.RoomSearch{Room: "room", Token: token, SearchQuery: "test"})
+	require.NoError(t, err)
+
+	m := new(server.FileSearch)
+	err = m.Deserialize(read(t, user2, server.CodeFileSearch))
+	require.NoError(t, err)
+	assert.Equal(t, "user1", m.Username)
+	assert.Equal(t, token, m.Token)
+	assert.Equal(t, "test", m.SearchQuery)
+
+	// Only the members of the room are searched.
+	_, err = server.Write(user1, &server.UserSearch{Username: "user3", Token: token, SearchQuery: "user"})
+	require.NoError(t, err)
+
+	err = m.Deserialize(read(t, user3, server.CodeFileSearch))
+	require.NoError(t, err)
+	assert.Equal(t, "user", m.SearchQuery)
+}
+
+func TestConnectToPeer(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	_, err = server.Write(user1, &server.SetListenPort{Port: 2234})
+	require.NoError(t, err)
+
+	roundTrip(t, user1)
+
+	user2 := login(t, s, "user2", "password")
+	defer user2.Close()
+
+	token := soul.NewToken()
+	_, err = server.Write(user1, &server.ConnectToPeer{Token: token, Username: "user2", Type: peer.ConnectionType})
+	require.NoError(t, err)
+
+	m := new(server.ConnectToPeer)
+	err = m.Deserialize(read(t, user2, server.CodeConnectToPeer))
+	require.NoError(t, err)
+	assert.Equal(t, "user1", m.Username)
+	assert.Equal(t, peer.ConnectionType, m.Type)
+	assert.Equal(t, token, m.Token)
+	assert.Equal(t, 2234, m.Port)
+
+	_, err = server.Write(user1, &server.ConnectToPeer{Token: token, Username: "nobody", Type: peer.ConnectionType})
+	require.NoError(t, err)
+
+	c := new(server.CantConnectToPeer)
+	err = c.Deserialize(read(t, user1, server.CodeCantConnectToPeer))
+	require.NoError(t, err)
+	assert.Equal(t, token, c.Token)
+}
+
+func TestPossibleParents(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	_, err = server.Write(user1, &server.SetListenPort{Port: 2234})
+	require.NoError(t, err)
+
+	_, err = server.Write(user1, &server.AcceptChildren{Accept: true})
+	require.NoError(t, err)
+
+	roundTrip(t, user1)
+
+	user2 := login(t, s, "user2", "password")
+	defer user2.Close()
+
+	_, err = server.Write(user2, &server.HaveNoParent{Have: true})
+	require.NoError(t, err)
+
+	m := new(server.PossibleParents)
+	err = m.Deserialize(read(t, user2, server.CodePossibleParents))
+	require.NoError(t, err)
+	require.Len(t, m.Parents, 1)
+	assert.Equal(t, "user1", m.Parents[0].Username)
+	assert.Equal(t, 2234, m.Parents[0].Port)
+}
+
+func TestWatchUser(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+
+	user1 := login(t, s, "user1", "password")
+	defer user1.Close()
+
+	_, err = server.Write(user1, &server.WatchUser{Username: "user2"})
+	require.NoError(t, err)
+
+	w := new(server.WatchUser)
+	err = w.Deserialize(read(t, user1, server.CodeWatchUser))
+	require.NoError(t, err)
+	assert.False(t, w.Exists)
+
+	user2 := login(t, s, "user2", "password")
+
+	m := new(server.GetUserStatus)
+	err = m.Deserialize(read(t, user1, server.CodeGetUserStatus))
+	require.NoError(t, err)
+	assert.Equal(t, "user2", m.Username)
+	assert.Equal(t, server.StatusOnline, m.Status)
+
+	user2.Close()
+
+	m = new(server.GetUserStatus)
+	err = m.Deserialize(read(t, user1, server.CodeGetUserStatus))
+	require.NoError(t, err)
+	assert.Equal(t, server.StatusOffline, m.Status)
+}
+
+func dial(t *testing.T, s *Server) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%v", s.Host(), s.Port()))
+	require.NoError(t, err)
+
+	return conn
+}
+
+// login connects to the server, logs in and consumes all the messages sent after login.
+func login(t *testing.T, s *Server, username, password string) net.Conn {
+	t.Helper()
+
+	conn := dial(t, s)
+
+	_, err := server.Write(conn, &server.Login{Username: username, Password: password})
+	require.NoError(t, err)
+
+	m := new(server.Login)
+	err = m.Deserialize(read(t, conn, server.CodeLogin))
+	require.NoError(t, err)
+	assert.Equal(t, s.Greet, m.Greet)
+
+	read(t, conn, server.CodeExcludedSearchPhrases)
+
+	return conn
+}
+
+// roundTrip waits for the server to process all messages sent so far on conn.
+func roundTrip(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	_, err := server.Write(conn, &server.CheckPrivileges{})
+	require.NoError(t, err)
+
+	read(t, conn, server.CodeCheckPrivileges)
+}
+
+// read returns the next message of the given code, skipping any other messages.
+func read(t *testing.T, conn net.Conn, code server.Code) *bytes.Buffer {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for {
+		r, _, c, err := server.Read(conn)
+		require.NoError(t, err)
+
+		if c == code {
+			return r
+		}
+	}
+}